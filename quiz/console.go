@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// console is the connection between the quiz and the person taking it. Prompts are written to an io.Writer and answers
+// are read line by line from an io.Reader by a single long-lived goroutine, so that input which has been read ahead is
+// never lost between questions. This means that answers can be piped or scripted as well as typed.
+type console struct {
+	out     io.Writer
+	answers chan answer
+	done    chan struct{}
+}
+
+// answer is a single line read from the console's input or the error which stopped it from being read.
+type answer struct {
+	text string
+	err  error
+}
+
+// newConsole returns a console which reads answers from in and writes prompts to out. Close must be called once the
+// console is no longer needed.
+func newConsole(in io.Reader, out io.Writer) *console {
+	c := &console{
+		out:     out,
+		answers: make(chan answer),
+		done:    make(chan struct{}),
+	}
+	go c.readAnswers(in)
+	return c
+}
+
+func (c *console) readAnswers(in io.Reader) {
+	reader := bufio.NewReader(in)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if !c.send(answer{text: strings.TrimSpace(line)}) {
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				err = fmt.Errorf("read input: %s", err)
+			}
+			c.send(answer{err: err})
+			return
+		}
+	}
+}
+
+// send sends a to whoever is waiting for the next answer and returns false if the console has been closed instead.
+func (c *console) send(a answer) bool {
+	select {
+	case c.answers <- a:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// Answers returns the channel which each line of input is sent on. After the input is exhausted, an answer with err set
+// to io.EOF is sent.
+func (c *console) Answers() <-chan answer {
+	return c.answers
+}
+
+// Printf writes a formatted message to the console's output.
+func (c *console) Printf(format string, args ...any) {
+	fmt.Fprintf(c.out, format, args...)
+}
+
+// Input writes msg to the console's output and waits for the next line of input.
+func (c *console) Input(msg string) (string, error) {
+	c.Printf("%s ", msg)
+	a := <-c.answers
+	return a.text, a.err
+}
+
+// Close stops the console from reading any more input. A read from the input which is already in progress can't be
+// interrupted, but the goroutine doing it will exit as soon as it returns.
+func (c *console) Close() {
+	close(c.done)
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// expr is a parsed arithmetic expression, like the answer to a template question. Expressions are made of numbers,
+// variables, the operators + - * / % and ^ (power), parentheses and calls to the functions in nameToFunc.
+type expr struct {
+	root exprNode
+	// vars is the name of each variable which is used in the expression.
+	vars []string
+}
+
+type exprNode interface {
+	eval(vars map[string]float64) (float64, error)
+}
+
+type numberNode float64
+
+func (n numberNode) eval(map[string]float64) (float64, error) {
+	return float64(n), nil
+}
+
+type varNode string
+
+func (n varNode) eval(vars map[string]float64) (float64, error) {
+	v, ok := vars[string(n)]
+	if !ok {
+		return 0, fmt.Errorf("undefined variable %q", string(n))
+	}
+	return v, nil
+}
+
+type negateNode struct {
+	operand exprNode
+}
+
+func (n negateNode) eval(vars map[string]float64) (float64, error) {
+	v, err := n.operand.eval(vars)
+	return -v, err
+}
+
+type binaryNode struct {
+	op          byte
+	left, right exprNode
+}
+
+func (n binaryNode) eval(vars map[string]float64) (float64, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	case '/':
+		if right == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return left / right, nil
+	case '%':
+		if right == 0 {
+			return 0, errors.New("modulo by zero")
+		}
+		return math.Mod(left, right), nil
+	case '^':
+		return math.Pow(left, right), nil
+	}
+	return 0, fmt.Errorf("unknown operator %q", n.op)
+}
+
+type callNode struct {
+	name string
+	args []exprNode
+}
+
+func (n callNode) eval(vars map[string]float64) (float64, error) {
+	args := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(vars)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = v
+	}
+	v := nameToFunc[n.name].apply(args)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("%s of %v is not a number", n.name, args)
+	}
+	return v, nil
+}
+
+// exprFunc is a function which can be called in an expression with between minArgs and maxArgs arguments. A maxArgs of
+// -1 means that there is no maximum.
+type exprFunc struct {
+	minArgs, maxArgs int
+	apply            func(args []float64) float64
+}
+
+var nameToFunc = map[string]exprFunc{
+	"abs":   {1, 1, func(args []float64) float64 { return math.Abs(args[0]) }},
+	"floor": {1, 1, func(args []float64) float64 { return math.Floor(args[0]) }},
+	"ceil":  {1, 1, func(args []float64) float64 { return math.Ceil(args[0]) }},
+	"sqrt":  {1, 1, func(args []float64) float64 { return math.Sqrt(args[0]) }},
+	// round rounds to the nearest integer or, if it's given a second argument, to that many decimal places.
+	"round": {1, 2, func(args []float64) float64 {
+		if len(args) == 1 {
+			return math.Round(args[0])
+		}
+		scale := math.Pow(10, math.Round(args[1]))
+		return math.Round(args[0]*scale) / scale
+	}},
+	"min": {2, -1, func(args []float64) float64 {
+		lowest := args[0]
+		for _, arg := range args[1:] {
+			lowest = math.Min(lowest, arg)
+		}
+		return lowest
+	}},
+	"max": {2, -1, func(args []float64) float64 {
+		highest := args[0]
+		for _, arg := range args[1:] {
+			highest = math.Max(highest, arg)
+		}
+		return highest
+	}},
+}
+
+// parseExpr parses the expression s.
+func parseExpr(s string) (expr, error) {
+	p := &exprParser{s: s, varSet: map[string]bool{}}
+	root, err := p.parseSum()
+	if err != nil {
+		return expr{}, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return expr{}, fmt.Errorf("unexpected %q at offset %d of expression %q", p.s[p.pos], p.pos, s)
+	}
+	return expr{root: root, vars: p.vars}, nil
+}
+
+// eval evaluates the expression with each variable set to its value in vars. The result is an error if it isn't a
+// finite number.
+func (e expr) eval(vars map[string]float64) (float64, error) {
+	v, err := e.root.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, errors.New("result is not a number")
+	}
+	return v, nil
+}
+
+// exprParser is a recursive descent parser of expressions. From lowest to highest precedence, the grammar is:
+//
+//	sum     = product { ("+" | "-") product }
+//	product = unary { ("*" | "/" | "%") unary }
+//	unary   = "-" unary | power
+//	power   = primary [ "^" unary ]
+//	primary = number | name | name "(" sum { "," sum } ")" | "(" sum ")"
+type exprParser struct {
+	s      string
+	pos    int
+	vars   []string
+	varSet map[string]bool
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// peek returns the next non-space byte or 0 if there isn't one.
+func (p *exprParser) peek() byte {
+	p.skipSpace()
+	if p.pos == len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s at offset %d of expression %q", fmt.Sprintf(format, args...), p.pos, p.s)
+}
+
+func (p *exprParser) parseSum() (exprNode, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseProduct() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' && op != '%' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.peek() == '-' {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return negateNode{operand: operand}, nil
+	}
+	return p.parsePower()
+}
+
+func (p *exprParser) parsePower() (exprNode, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != '^' {
+		return base, nil
+	}
+	p.pos++
+	exponent, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return binaryNode{op: '^', left: base, right: exponent}, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return nil, p.errorf("unexpected end")
+	case c == '(':
+		p.pos++
+		node, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, p.errorf("missing )")
+		}
+		p.pos++
+		return node, nil
+	case c == '.' || isDigit(c):
+		start := p.pos
+		for p.pos < len(p.s) && (p.s[p.pos] == '.' || isDigit(p.s[p.pos])) {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+		if err != nil {
+			number := p.s[start:p.pos]
+			p.pos = start
+			return nil, p.errorf("invalid number %q", number)
+		}
+		return numberNode(v), nil
+	case isNameStart(c):
+		start := p.pos
+		for p.pos < len(p.s) && isNamePart(p.s[p.pos]) {
+			p.pos++
+		}
+		name := p.s[start:p.pos]
+		if p.peek() == '(' {
+			return p.parseCall(name)
+		}
+		if !p.varSet[name] {
+			p.varSet[name] = true
+			p.vars = append(p.vars, name)
+		}
+		return varNode(name), nil
+	}
+	return nil, p.errorf("unexpected %q", c)
+}
+
+func (p *exprParser) parseCall(name string) (exprNode, error) {
+	f, ok := nameToFunc[name]
+	if !ok {
+		return nil, p.errorf("unknown function %q", name)
+	}
+	p.pos++ // (
+	var args []exprNode
+	if p.peek() != ')' {
+		for {
+			arg, err := p.parseSum()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+	}
+	if p.peek() != ')' {
+		return nil, p.errorf("missing ) after arguments to %s", name)
+	}
+	p.pos++
+	if len(args) < f.minArgs || f.maxArgs >= 0 && len(args) > f.maxArgs {
+		return nil, p.errorf("%s called with %d arguments", name, len(args))
+	}
+	return callNode{name: name, args: args}, nil
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isNamePart(c byte) bool {
+	return isNameStart(c) || isDigit(c)
+}
+
+// isValidName reports whether s can be used as the name of a variable in an expression.
+func isValidName(s string) bool {
+	if s == "" || !isNameStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isNamePart(s[i]) {
+			return false
+		}
+	}
+	_, isFunc := nameToFunc[s]
+	return !isFunc
+}
+
+// formatNumber formats v in the shortest way, without an exponent, after rounding away floating point error so that
+// 0.1 + 0.2 is formatted as 0.3.
+func formatNumber(v float64) string {
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(v, 'f', 9, 64), 64)
+	if err != nil {
+		rounded = v
+	}
+	if rounded == 0 {
+		rounded = 0 // Avoids formatting negative zero as -0.
+	}
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// generatorConfig describes a bank of arithmetic questions to generate.
+type generatorConfig struct {
+	operators  []string
+	count      int
+	difficulty int
+	seed       int64
+}
+
+// operator is an arithmetic operator which questions can be generated for.
+type operator struct {
+	symbol string
+	// operands returns a pair of operands for a question using the operator where each is at most max in magnitude.
+	operands func(rng *rand.Rand, max int, negatives bool) (int, int)
+	apply    func(a, b int) int
+}
+
+var nameToOperator = map[string]operator{
+	"add": {
+		symbol:   "+",
+		operands: randomOperands,
+		apply:    func(a, b int) int { return a + b },
+	},
+	"sub": {
+		symbol:   "-",
+		operands: randomOperands,
+		apply:    func(a, b int) int { return a - b },
+	},
+	"mul": {
+		symbol: "*",
+		operands: func(rng *rand.Rand, max int, negatives bool) (int, int) {
+			return randomOperands(rng, mulOperandMax(max), negatives)
+		},
+		apply: func(a, b int) int { return a * b },
+	},
+	"div": {
+		symbol: "/",
+		// The dividend is built from the divisor and quotient so that the answer is always an integer.
+		operands: func(rng *rand.Rand, max int, negatives bool) (int, int) {
+			divisor, quotient := randomOperands(rng, mulOperandMax(max), negatives)
+			for divisor == 0 {
+				divisor = randomOperand(rng, mulOperandMax(max), negatives)
+			}
+			return divisor * quotient, divisor
+		},
+		apply: func(a, b int) int { return a / b },
+	},
+}
+
+// difficultyToMaxOperand is the largest operand magnitude at each difficulty, from 1 to 5.
+var difficultyToMaxOperand = []int{10, 20, 50, 100, 1000}
+
+// minNegativesDifficulty is the lowest difficulty which generates negative operands.
+const minNegativesDifficulty = 3
+
+// parseOperators parses a comma separated list of operator names.
+func parseOperators(s string) ([]string, error) {
+	var operators []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := nameToOperator[name]; !ok {
+			return nil, fmt.Errorf("unknown operator %q, expected one of add, sub, mul or div", name)
+		}
+		operators = append(operators, name)
+	}
+	return operators, nil
+}
+
+// generateQuestions generates config.count arithmetic questions using a random choice of config.operators for each.
+// The same config always generates the same questions.
+func generateQuestions(config generatorConfig) ([]question, error) {
+	if len(config.operators) == 0 {
+		return nil, fmt.Errorf("no operators given")
+	}
+	if config.count <= 0 {
+		return nil, fmt.Errorf("count must be positive, got %d", config.count)
+	}
+	if config.difficulty < 1 || config.difficulty > len(difficultyToMaxOperand) {
+		return nil, fmt.Errorf("difficulty must be between 1 and %d, got %d", len(difficultyToMaxOperand), config.difficulty)
+	}
+
+	rng := rand.New(rand.NewSource(config.seed))
+	max := difficultyToMaxOperand[config.difficulty-1]
+	negatives := config.difficulty >= minNegativesDifficulty
+
+	questions := make([]question, config.count)
+	for i := range questions {
+		op := nameToOperator[config.operators[rng.Intn(len(config.operators))]]
+		a, b := op.operands(rng, max, negatives)
+		questions[i] = question{
+			kind:     textQuestion,
+			question: fmt.Sprintf("%s%s%s", formatOperand(a), op.symbol, formatOperand(b)),
+			answers:  []string{strconv.Itoa(op.apply(a, b))},
+			match:    matchRule{mode: matchNumeric},
+			weight:   1,
+		}
+	}
+	return questions, nil
+}
+
+func (c generatorConfig) String() string {
+	return fmt.Sprintf("generated:%s:difficulty=%d", strings.Join(c.operators, ","), c.difficulty)
+}
+
+func randomOperands(rng *rand.Rand, max int, negatives bool) (int, int) {
+	return randomOperand(rng, max, negatives), randomOperand(rng, max, negatives)
+}
+
+func randomOperand(rng *rand.Rand, max int, negatives bool) int {
+	if negatives {
+		return rng.Intn(2*max+1) - max
+	}
+	return rng.Intn(max) + 1
+}
+
+// mulOperandMax scales down the operand range for multiplication and division so that the answers stay in the same
+// range as they are for addition.
+func mulOperandMax(max int) int {
+	mulMax := 1
+	for mulMax*mulMax < max*2 {
+		mulMax++
+	}
+	return mulMax
+}
+
+func formatOperand(n int) string {
+	if n < 0 {
+		return fmt.Sprintf("(%d)", n)
+	}
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGenerateQuestionsIsDeterministic(t *testing.T) {
+	config := generatorConfig{operators: []string{"add", "sub", "mul", "div"}, count: 50, difficulty: 4, seed: 42}
+
+	first, err := generateQuestions(config)
+	if err != nil {
+		t.Fatalf("generateQuestions(%+v) returned unexpected err: %s", config, err)
+	}
+	second, err := generateQuestions(config)
+	if err != nil {
+		t.Fatalf("generateQuestions(%+v) returned unexpected err: %s", config, err)
+	}
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("generateQuestions(%+v) returned different questions for the same seed", config)
+	}
+}
+
+func TestGenerateQuestionsDivisionHasIntegerAnswers(t *testing.T) {
+	config := generatorConfig{operators: []string{"div"}, count: 100, difficulty: 5, seed: 1}
+
+	questions, err := generateQuestions(config)
+	if err != nil {
+		t.Fatalf("generateQuestions(%+v) returned unexpected err: %s", config, err)
+	}
+
+	for _, q := range questions {
+		operands := strings.Split(strings.NewReplacer("(", "", ")", "").Replace(q.question), "/")
+		dividend, _ := strconv.Atoi(operands[0])
+		divisor, _ := strconv.Atoi(operands[1])
+		if divisor == 0 || dividend%divisor != 0 {
+			t.Errorf("generated question %q does not have an integer answer", q.question)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+module github.com/marcuscaisey/gophercises/quiz
+
+go 1.18
+
+require gopkg.in/yaml.v3 v3.0.0-20220512140231-539c8e751b99
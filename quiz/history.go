@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+const configDir = ".quiz"
+const defaultHistoryFile = "history.jsonl"
+
+// maxHardestQuestions is the number of questions listed in the hardest questions section of the stats report.
+const maxHardestQuestions = 10
+
+// attempt is a record of a single run of a quiz as it is stored in the history file. The history file holds one attempt
+// per line encoded as JSON.
+type attempt struct {
+	Time    time.Time       `json:"time"`
+	Bank    string          `json:"bank"`
+	Score   int             `json:"score"`
+	Total   int             `json:"total"`
+	Answers []attemptAnswer `json:"answers"`
+}
+
+type attemptAnswer struct {
+	Question       string `json:"question"`
+	Answer         string `json:"answer"`
+	Correct        bool   `json:"correct"`
+	TimedOut       bool   `json:"timed_out,omitempty"`
+	ResponseTimeMS int64  `json:"response_time_ms"`
+}
+
+func newAttempt(started time.Time, bank string, total int, result quizResult) attempt {
+	a := attempt{
+		Time:  started,
+		Bank:  bank,
+		Score: result.score,
+		Total: total,
+	}
+	for _, given := range result.answers {
+		a.Answers = append(a.Answers, attemptAnswer{
+			Question:       given.question.question,
+			Answer:         given.answer,
+			Correct:        given.correct,
+			TimedOut:       given.timedOut,
+			ResponseTimeMS: given.elapsed.Milliseconds(),
+		})
+	}
+	return a
+}
+
+// resolveHistoryPath returns path or, if it's empty, the default history file in the user's home directory.
+func resolveHistoryPath(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("determine home directory: %s", err)
+	}
+	return filepath.Join(homeDir, configDir, defaultHistoryFile), nil
+}
+
+// bankName returns the name that the questions loaded from path are recorded under in the history file.
+func bankName(path string) string {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return absPath
+}
+
+// appendAttempt adds a to the end of the history file at path, creating it if it doesn't exist.
+func appendAttempt(path string, a attempt) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("create history directory: %s", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("open history file: %s", err)
+	}
+	defer f.Close()
+
+	if err := json.NewEncoder(f).Encode(a); err != nil {
+		return fmt.Errorf("write attempt to history file: %s", err)
+	}
+	return nil
+}
+
+// readAttempts returns the attempts in the history file at path in the order that they were recorded. If bank is not
+// empty, then only the attempts at that bank are returned. A history file which doesn't exist has no attempts.
+func readAttempts(path string, bank string) ([]attempt, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open history file: %s", err)
+	}
+	defer f.Close()
+
+	var attempts []attempt
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var a attempt
+		if err := json.Unmarshal(scanner.Bytes(), &a); err != nil {
+			return nil, fmt.Errorf("%s:%d: decode attempt: %s", path, line, err)
+		}
+		if bank == "" || a.Bank == bank {
+			attempts = append(attempts, a)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read history file: %s", err)
+	}
+	return attempts, nil
+}
+
+// questionStats are the statistics for a single question across all of the attempts which asked it.
+type questionStats struct {
+	bank              string
+	question          string
+	asked             int
+	correct           int
+	timedOut          int
+	totalResponseTime time.Duration
+}
+
+func (s questionStats) accuracy() float64 {
+	return float64(s.correct) / float64(s.asked)
+}
+
+func (s questionStats) averageResponseTime() time.Duration {
+	return s.totalResponseTime / time.Duration(s.asked)
+}
+
+// collectQuestionStats returns the statistics for each question which has been answered in attempts, ordered from the
+// hardest to the easiest. Questions with lower accuracy are harder and ties are broken by the number of times that
+// they've been asked and then by their average response time.
+func collectQuestionStats(attempts []attempt) []questionStats {
+	type key struct{ bank, question string }
+	keyToStats := map[key]*questionStats{}
+	var keys []key
+	for _, a := range attempts {
+		for _, answer := range a.Answers {
+			k := key{a.Bank, answer.Question}
+			stats, ok := keyToStats[k]
+			if !ok {
+				stats = &questionStats{bank: a.Bank, question: answer.Question}
+				keyToStats[k] = stats
+				keys = append(keys, k)
+			}
+			stats.asked++
+			if answer.Correct {
+				stats.correct++
+			}
+			if answer.TimedOut {
+				stats.timedOut++
+			}
+			stats.totalResponseTime += time.Duration(answer.ResponseTimeMS) * time.Millisecond
+		}
+	}
+
+	allStats := make([]questionStats, len(keys))
+	for i, k := range keys {
+		allStats[i] = *keyToStats[k]
+	}
+	sort.SliceStable(allStats, func(i, j int) bool {
+		a, b := allStats[i], allStats[j]
+		if a.accuracy() != b.accuracy() {
+			return a.accuracy() < b.accuracy()
+		}
+		if a.asked != b.asked {
+			return a.asked > b.asked
+		}
+		return a.averageResponseTime() > b.averageResponseTime()
+	})
+	return allStats
+}
+
+// reportStats writes a report of the hardest questions, the average response time to each question and the score of
+// each attempt over time to out. If bank is not empty, then only the attempts at that bank are included.
+func reportStats(historyPath string, bank string, out io.Writer) error {
+	attempts, err := readAttempts(historyPath, bank)
+	if err != nil {
+		return fmt.Errorf("read attempts: %s", err)
+	}
+	if len(attempts) == 0 {
+		fmt.Fprintf(out, "No attempts recorded in %s\n", historyPath)
+		return nil
+	}
+
+	allStats := collectQuestionStats(attempts)
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "Hardest questions:")
+	fmt.Fprintln(tw, "  Question\tCorrect\tTimed out\tAvg time\t")
+	for i, stats := range allStats {
+		if i == maxHardestQuestions {
+			break
+		}
+		fmt.Fprintf(tw, "  %s\t%d / %d (%.0f%%)\t%d\t%s\t\n", stats.question, stats.correct, stats.asked, 100*stats.accuracy(), stats.timedOut, stats.averageResponseTime().Round(time.Millisecond))
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "Average response time per question:")
+	sort.SliceStable(allStats, func(i, j int) bool {
+		return allStats[i].averageResponseTime() > allStats[j].averageResponseTime()
+	})
+	for _, stats := range allStats {
+		fmt.Fprintf(tw, "  %s\t%s\t\n", stats.question, stats.averageResponseTime().Round(time.Millisecond))
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "Score trend:")
+	for _, a := range attempts {
+		percent := 0.0
+		if a.Total > 0 {
+			percent = 100 * float64(a.Score) / float64(a.Total)
+		}
+		fmt.Fprintf(tw, "  %s\t%d / %d\t%.0f%%\t%s\t\n", a.Time.Local().Format("2006-01-02 15:04"), a.Score, a.Total, percent, filepath.Base(a.Bank))
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("write stats: %s", err)
+	}
+	return nil
+}
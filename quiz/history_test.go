@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendAndReadAttempts(t *testing.T) {
+	historyPath := filepath.Join(t.TempDir(), "nested", "history.jsonl")
+	started := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	attempts := []attempt{
+		{Time: started, Bank: "/a.csv", Score: 1, Total: 2, Answers: []attemptAnswer{
+			{Question: "1+1", Answer: "2", Correct: true, ResponseTimeMS: 1500},
+			{Question: "2+2", TimedOut: true, ResponseTimeMS: 5000},
+		}},
+		{Time: started.Add(time.Hour), Bank: "/b.csv", Score: 0, Total: 1, Answers: []attemptAnswer{
+			{Question: "3+3", Answer: "7", ResponseTimeMS: 2000},
+		}},
+		{Time: started.Add(2 * time.Hour), Bank: "/a.csv", Score: 2, Total: 2, Answers: []attemptAnswer{
+			{Question: "1+1", Answer: "2", Correct: true, ResponseTimeMS: 500},
+			{Question: "2+2", Answer: "4", Correct: true, ResponseTimeMS: 1000},
+		}},
+	}
+	for _, a := range attempts {
+		if err := appendAttempt(historyPath, a); err != nil {
+			t.Fatalf("appendAttempt returned unexpected err: %s", err)
+		}
+	}
+
+	testCases := []struct {
+		name string
+		bank string
+		want []attempt
+	}{
+		{
+			name: "all banks",
+			bank: "",
+			want: attempts,
+		},
+		{
+			name: "single bank",
+			bank: "/a.csv",
+			want: []attempt{attempts[0], attempts[2]},
+		},
+		{
+			name: "bank without attempts",
+			bank: "/c.csv",
+			want: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := readAttempts(historyPath, tc.bank)
+			if err != nil {
+				t.Fatalf("readAttempts(%q) returned unexpected err: %s", tc.bank, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("readAttempts(%q) = %+v, want %+v", tc.bank, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadAttemptsFromMissingFile(t *testing.T) {
+	historyPath := filepath.Join(t.TempDir(), "history.jsonl")
+	got, err := readAttempts(historyPath, "")
+	if err != nil || got != nil {
+		t.Errorf("readAttempts of missing file = %+v, %v, want nil, nil", got, err)
+	}
+}
+
+func TestReadAttemptsReturnsLineNumberedErrors(t *testing.T) {
+	historyPath := filepath.Join(t.TempDir(), "history.jsonl")
+	mustWriteFile(t, historyPath, `{"bank": "/a.csv", "score": 1}`+"\n\nnot json\n")
+	_, err := readAttempts(historyPath, "")
+	if wantErr := historyPath + ":3: decode attempt: "; err == nil || !strings.HasPrefix(err.Error(), wantErr) {
+		t.Errorf("readAttempts returned err: %v, want err starting with %q", err, wantErr)
+	}
+}
+
+func TestCollectQuestionStats(t *testing.T) {
+	attempts := []attempt{
+		{Bank: "/a.csv", Answers: []attemptAnswer{
+			{Question: "easy", Correct: true, ResponseTimeMS: 1000},
+			{Question: "hard", TimedOut: true, ResponseTimeMS: 5000},
+			{Question: "slow", Correct: true, ResponseTimeMS: 4000},
+			{Question: "medium", Correct: false, ResponseTimeMS: 2000},
+		}},
+		{Bank: "/a.csv", Answers: []attemptAnswer{
+			{Question: "easy", Correct: true, ResponseTimeMS: 3000},
+			{Question: "hard", Correct: false, ResponseTimeMS: 3000},
+			{Question: "medium", Correct: true, ResponseTimeMS: 2000},
+		}},
+		{Bank: "/b.csv", Answers: []attemptAnswer{
+			{Question: "easy", Correct: false, ResponseTimeMS: 1000},
+		}},
+	}
+
+	got := collectQuestionStats(attempts)
+
+	// Questions are ordered by accuracy, then by how often they've been asked and then by average response time.
+	want := []questionStats{
+		{bank: "/a.csv", question: "hard", asked: 2, correct: 0, timedOut: 1, totalResponseTime: 8 * time.Second},
+		{bank: "/b.csv", question: "easy", asked: 1, correct: 0, totalResponseTime: time.Second},
+		{bank: "/a.csv", question: "medium", asked: 2, correct: 1, totalResponseTime: 4 * time.Second},
+		{bank: "/a.csv", question: "easy", asked: 2, correct: 2, totalResponseTime: 4 * time.Second},
+		{bank: "/a.csv", question: "slow", asked: 1, correct: 1, totalResponseTime: 4 * time.Second},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collectQuestionStats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReportStats(t *testing.T) {
+	historyPath := filepath.Join(t.TempDir(), "history.jsonl")
+	started := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	for _, a := range []attempt{
+		{Time: started, Bank: "/banks/a.csv", Score: 1, Total: 2, Answers: []attemptAnswer{
+			{Question: "1+1", Answer: "2", Correct: true, ResponseTimeMS: 1000},
+			{Question: "2+2", TimedOut: true, ResponseTimeMS: 5000},
+		}},
+		{Time: started.Add(time.Hour), Bank: "/banks/b.csv", Score: 0, Total: 1, Answers: []attemptAnswer{
+			{Question: "3+3", Answer: "7", ResponseTimeMS: 2000},
+		}},
+	} {
+		if err := appendAttempt(historyPath, a); err != nil {
+			t.Fatalf("appendAttempt returned unexpected err: %s", err)
+		}
+	}
+
+	testCases := []struct {
+		name string
+		bank string
+		want string
+	}{
+		{
+			name: "all banks",
+			bank: "",
+			want: `Hardest questions:
+  Question  Correct       Timed out  Avg time  
+  2+2       0 / 1 (0%)    1          5s        
+  3+3       0 / 1 (0%)    0          2s        
+  1+1       1 / 1 (100%)  0          1s        
+
+Average response time per question:
+  2+2  5s  
+  3+3  2s  
+  1+1  1s  
+
+Score trend:
+  2024-01-02 03:04  1 / 2  50%  a.csv  
+  2024-01-02 04:04  0 / 1  0%   b.csv  
+`,
+		},
+		{
+			name: "single bank",
+			bank: "/banks/b.csv",
+			want: `Hardest questions:
+  Question  Correct     Timed out  Avg time  
+  3+3       0 / 1 (0%)  0          2s        
+
+Average response time per question:
+  3+3  2s  
+
+Score trend:
+  2024-01-02 04:04  0 / 1  0%  b.csv  
+`,
+		},
+		{
+			name: "bank without attempts",
+			bank: "/banks/c.csv",
+			want: "No attempts recorded in " + historyPath + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := reportStats(historyPath, tc.bank, &out); err != nil {
+				t.Fatalf("reportStats(%q) returned unexpected err: %s", tc.bank, err)
+			}
+			if out.String() != tc.want {
+				t.Errorf("reportStats(%q) wrote:\n%s\nwant:\n%s", tc.bank, out.String(), tc.want)
+			}
+		})
+	}
+}
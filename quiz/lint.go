@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// lintIssue is a problem found in a problems file.
+type lintIssue struct {
+	pos position
+	msg string
+}
+
+// lintPaths checks each of the problems files at paths, or in them if they're directories, and writes any issues found
+// to out. It returns the number of issues found.
+func lintPaths(paths []string, format string, out io.Writer) (int, error) {
+	if len(paths) == 0 {
+		return 0, errors.New("no problems files given to lint")
+	}
+
+	numIssues := 0
+	for _, path := range paths {
+		files, err := problemsFiles(path, format)
+		if err != nil {
+			return 0, err
+		}
+		for _, file := range files {
+			issues, err := lintFile(file, format)
+			if err != nil {
+				return 0, err
+			}
+			for _, issue := range issues {
+				fmt.Fprintf(out, "%s:%s: %s\n", file, issue.pos, issue.msg)
+			}
+			numIssues += len(issues)
+		}
+	}
+	return numIssues, nil
+}
+
+// problemsFiles returns path if it's a file or the problems files in it if it's a directory.
+func problemsFiles(path string, format string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("stat problems path: %s", err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("read problems directory: %s", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		file := filepath.Join(path, entry.Name())
+		if entry.IsDir() {
+			continue
+		}
+		if _, ok := formatToLoader[extFormat(file)]; format == "" && !ok {
+			continue
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// lintFile returns the issues in the problems file at path. An issue which stops the file from being parsed at all is
+// returned as the only issue.
+func lintFile(path string, format string) ([]lintIssue, error) {
+	loader, err := loaderFor(path, format)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read problems file: %s", err)
+	}
+
+	issues := lintEncoding(data)
+
+	b, err := loader.Parse(bytes.NewReader(data))
+	if err != nil {
+		return append(issues, lintIssue{pos: errorPosition(err), msg: err.Error()}), nil
+	}
+	issues = append(issues, lintRecords(b)...)
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].pos.line != issues[j].pos.line {
+			return issues[i].pos.line < issues[j].pos.line
+		}
+		return issues[i].pos.column < issues[j].pos.column
+	})
+	return issues, nil
+}
+
+// lintEncoding returns an issue for a byte order mark at the start of data and for each line which isn't valid UTF-8.
+func lintEncoding(data []byte) []lintIssue {
+	var issues []lintIssue
+	if bytes.HasPrefix(data, []byte("\xef\xbb\xbf")) {
+		issues = append(issues, lintIssue{pos: position{1, 1}, msg: "file starts with a UTF-8 byte order mark"})
+	}
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		for column := 0; column < len(line); {
+			r, size := utf8.DecodeRune(line[column:])
+			if r == utf8.RuneError && size == 1 {
+				issues = append(issues, lintIssue{pos: position{i + 1, column + 1}, msg: "invalid UTF-8"})
+				break
+			}
+			if r == 0 {
+				issues = append(issues, lintIssue{pos: position{i + 1, column + 1}, msg: "NUL byte"})
+				break
+			}
+			column += size
+		}
+	}
+	return issues
+}
+
+// lintRecords returns the issues with the records in b.
+func lintRecords(b bank) []lintIssue {
+	var issues []lintIssue
+	questionToPos := map[string]position{}
+	for _, record := range b.records {
+		// fieldPos returns the position of field in the record, falling back to the start of the record.
+		fieldPos := func(field string) position {
+			if pos, ok := record.fieldPos[field]; ok {
+				return pos
+			}
+			return record.pos
+		}
+		// errorPos returns the position of the field that err is in, falling back to the start of the record.
+		errorPos := func(err error) position {
+			var fieldErr fieldError
+			if errors.As(err, &fieldErr) {
+				return fieldPos(fieldErr.field)
+			}
+			return record.pos
+		}
+
+		if record.err != nil {
+			issues = append(issues, lintIssue{pos: errorPos(record.err), msg: record.err.Error()})
+			continue
+		}
+
+		valid := true
+		if record.Question == nil {
+			issues = append(issues, lintIssue{pos: record.pos, msg: "missing question field"})
+			valid = false
+		} else if text := strings.TrimSpace(*record.Question); text == "" {
+			issues = append(issues, lintIssue{pos: fieldPos("question"), msg: "empty question"})
+		} else if firstPos, ok := questionToPos[text]; ok {
+			issues = append(issues, lintIssue{pos: fieldPos("question"), msg: fmt.Sprintf("duplicate question %q, first defined at %s", text, firstPos)})
+		} else {
+			questionToPos[text] = fieldPos("question")
+		}
+
+		if record.Answer == nil && len(record.Answers) == 0 && record.Expr == "" {
+			issues = append(issues, lintIssue{pos: record.pos, msg: "missing answer field"})
+			valid = false
+		}
+		if record.Answer != nil && strings.TrimSpace(*record.Answer) == "" {
+			issues = append(issues, lintIssue{pos: fieldPos("answer"), msg: "empty answer"})
+			valid = false
+		}
+		for _, answer := range record.Answers {
+			if strings.TrimSpace(answer) == "" {
+				issues = append(issues, lintIssue{pos: fieldPos("answers"), msg: "empty answer"})
+				valid = false
+				break
+			}
+		}
+
+		if !valid {
+			continue
+		}
+		if _, err := record.toQuestion(b.defaults); err != nil {
+			issues = append(issues, lintIssue{pos: errorPos(err), msg: err.Error()})
+		}
+	}
+	return issues
+}
+
+// errorPosition returns the position of a parse error or the start of the file if it doesn't have one.
+func errorPosition(err error) position {
+	var parseErr parseError
+	if errors.As(err, &parseErr) {
+		return parseErr.pos
+	}
+	return position{line: 1, column: 1}
+}
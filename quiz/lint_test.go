@@ -0,0 +1,64 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLintFile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		file     string
+		contents string
+		want     []lintIssue
+	}{
+		{
+			name:     "valid csv has no issues",
+			file:     "problems.csv",
+			contents: "5+5,10\n1+1,2\n",
+			want:     nil,
+		},
+		{
+			name:     "csv issues are reported at their field",
+			file:     "problems.csv",
+			contents: "# match: numeric\n5+5,10\n7+3,\n5+5,10\n1+1,two\n8+3\n",
+			want: []lintIssue{
+				{pos: position{3, 5}, msg: "empty answer"},
+				{pos: position{4, 1}, msg: `duplicate question "5+5", first defined at 2:1`},
+				{pos: position{5, 5}, msg: `answer "two" is not a number`},
+				{pos: position{6, 1}, msg: "expected at least 2 fields (question, answer), got 1"},
+			},
+		},
+		{
+			name:     "yaml issues are reported at their field",
+			file:     "problems.yaml",
+			contents: "- question: Primes?\n  type: choice\n  options: [2, 4]\n  answer: c\n- answer: 1\n",
+			want: []lintIssue{
+				{pos: position{4, 11}, msg: `answer "c" is not the letter of one of the 2 options`},
+				{pos: position{5, 3}, msg: "missing question field"},
+			},
+		},
+		{
+			name:     "invalid utf-8 is reported",
+			file:     "problems.csv",
+			contents: "5+5,10\n\xff,1\n",
+			want:     []lintIssue{{pos: position{2, 1}, msg: "invalid UTF-8"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.file)
+			mustWriteFile(t, path, tc.contents)
+
+			got, err := lintFile(path, "")
+			if err != nil {
+				t.Fatalf("lintFile(%q) returned unexpected err: %s", path, err)
+			}
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("lintFile(%q) = %v, want %v", tc.contents, got, tc.want)
+			}
+		})
+	}
+}
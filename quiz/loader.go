@@ -0,0 +1,784 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// questionLoader reads the questions out of a problems file in a particular format.
+type questionLoader interface {
+	// Load reads the questions from r, returning an error if any of them are invalid.
+	Load(r io.Reader) ([]question, error)
+	// Parse reads the records from r without checking whether they're valid questions.
+	Parse(r io.Reader) (bank, error)
+}
+
+var formatToLoader = map[string]questionLoader{
+	"csv":  csvLoader{},
+	"json": jsonLoader{},
+	"yaml": yamlLoader{},
+	"yml":  yamlLoader{},
+}
+
+// readQuestions reads the questions from the problems file at path. If path is a directory, then the questions from
+// each problems file in it are merged together in filename order. The loader used for each file is chosen by format or,
+// if format is empty, by the file's extension.
+func readQuestions(path string, format string) ([]question, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("stat problems path: %s", err)
+	}
+	if info.IsDir() {
+		return readQuestionsDir(path, format)
+	}
+	return readQuestionsFile(path, format)
+}
+
+func readQuestionsDir(dir string, format string) ([]question, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read problems directory: %s", err)
+	}
+
+	var questions []question
+	filesRead := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if format == "" {
+			if _, ok := formatToLoader[extFormat(path)]; !ok {
+				continue
+			}
+		}
+		fileQuestions, err := readQuestionsFile(path, format)
+		if err != nil {
+			return nil, err
+		}
+		questions = append(questions, fileQuestions...)
+		filesRead++
+	}
+
+	if filesRead == 0 {
+		return nil, fmt.Errorf("no problems files found in %s", dir)
+	}
+	return questions, nil
+}
+
+func readQuestionsFile(path string, format string) ([]question, error) {
+	loader, err := loaderFor(path, format)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open problems file: %s", err)
+	}
+	defer f.Close()
+
+	questions, err := loader.Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %s", path, err)
+	}
+	return questions, nil
+}
+
+func loaderFor(path string, format string) (questionLoader, error) {
+	if format == "" {
+		format = extFormat(path)
+	}
+	loader, ok := formatToLoader[strings.ToLower(format)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format %q for problems file %s", format, path)
+	}
+	return loader, nil
+}
+
+func extFormat(path string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// bank is the contents of a problems file before its records have been turned into questions.
+type bank struct {
+	defaults bankDefaults
+	records  []questionRecord
+}
+
+// bankDefaults are the file-wide settings of a problems file which apply to every record that doesn't override them.
+type bankDefaults struct {
+	Match    string `json:"match" yaml:"match"`
+	Category string `json:"category" yaml:"category"`
+}
+
+// questionRecord is a single question as it is written in a problems file.
+type questionRecord struct {
+	// pos is the position in the problems file that the record starts at.
+	pos position
+	// fieldPos is the position of the value of each field which is present in the record.
+	fieldPos map[string]position
+	// err is set if the record couldn't be parsed, like a CSV row without enough fields.
+	err      error
+	Question *string  `json:"question" yaml:"question"`
+	Answer   *string  `json:"answer" yaml:"answer"`
+	Answers  []string `json:"answers" yaml:"answers"`
+	Match    string   `json:"match" yaml:"match"`
+	Type     string   `json:"type" yaml:"type"`
+	Options  []string `json:"options" yaml:"options"`
+	Category string   `json:"category" yaml:"category"`
+	Tags     []string `json:"tags" yaml:"tags"`
+	Weight   *float64 `json:"weight" yaml:"weight"`
+	Hint     string   `json:"hint" yaml:"hint"`
+	// Vars, Expr and Count are set for template questions, see questionTemplate.
+	Vars  map[string]string `json:"vars" yaml:"vars"`
+	Expr  string            `json:"expr" yaml:"expr"`
+	Count *int              `json:"count" yaml:"count"`
+}
+
+// position is a 1-indexed line and column in a problems file. For CSV, the column is the byte index of the field in
+// the line.
+type position struct {
+	line, column int
+}
+
+func (p position) String() string {
+	return fmt.Sprintf("%d:%d", p.line, p.column)
+}
+
+// parseError is an error at a particular position in a problems file.
+type parseError struct {
+	pos position
+	msg string
+}
+
+func (e parseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.pos.line, e.msg)
+}
+
+// lineErrorf returns a parseError at the start of the given line.
+func lineErrorf(line int, format string, args ...any) error {
+	return parseError{pos: position{line: line, column: 1}, msg: fmt.Sprintf(format, args...)}
+}
+
+// fieldError is an error in the value of a particular field of a record.
+type fieldError struct {
+	field string
+	err   error
+}
+
+func (e fieldError) Error() string {
+	return e.err.Error()
+}
+
+// questions converts each of the bank's records into a question, returning an error prefixed with the line of the
+// first record which isn't valid.
+func (b bank) questions() ([]question, error) {
+	questions := make([]question, 0, len(b.records))
+	for _, record := range b.records {
+		if record.err != nil {
+			return nil, parseError{pos: record.pos, msg: record.err.Error()}
+		}
+		question, err := record.toQuestion(b.defaults)
+		if err != nil {
+			return nil, parseError{pos: record.pos, msg: err.Error()}
+		}
+		questions = append(questions, question)
+	}
+	return questions, nil
+}
+
+// answerField returns the name of the field that the record's answers are in.
+func (r questionRecord) answerField() string {
+	if r.Answer != nil {
+		return "answer"
+	}
+	return "answers"
+}
+
+func (r questionRecord) toQuestion(defaults bankDefaults) (question, error) {
+	if r.Question == nil {
+		return question{}, errors.New("missing question field")
+	}
+
+	var answers []string
+	if r.Answer != nil {
+		answers = append(answers, *r.Answer)
+	}
+	answers = append(answers, r.Answers...)
+	isTemplate := r.Expr != ""
+	if isTemplate && len(answers) > 0 {
+		return question{}, fieldError{r.answerField(), errors.New("answers can't be given for a template question, they're worked out from expr")}
+	}
+	if !isTemplate && len(answers) == 0 {
+		return question{}, errors.New("missing answer field")
+	}
+	if !isTemplate && len(r.Vars) > 0 {
+		return question{}, fieldError{"vars", errors.New("vars are only supported by template questions, which have an expr")}
+	}
+	if !isTemplate && r.Count != nil {
+		return question{}, fieldError{"count", errors.New("count is only supported by template questions, which have an expr")}
+	}
+
+	kind := strings.ToLower(strings.TrimSpace(r.Type))
+	if kind == "" {
+		kind = textQuestion
+	}
+	if kind != choiceQuestion && len(r.Options) > 0 {
+		return question{}, fieldError{"options", fmt.Errorf("options are only supported by %s questions", choiceQuestion)}
+	}
+	if kind != textQuestion && r.Match != "" {
+		return question{}, fieldError{"match", fmt.Errorf("match is only supported by %s questions", textQuestion)}
+	}
+	if kind != textQuestion && isTemplate {
+		return question{}, fieldError{"expr", fmt.Errorf("expr is only supported by %s questions", textQuestion)}
+	}
+
+	q := question{
+		kind:     kind,
+		question: *r.Question,
+		category: strings.TrimSpace(r.Category),
+		weight:   1,
+		hint:     strings.TrimSpace(r.Hint),
+	}
+	if r.Weight != nil {
+		if *r.Weight < 0 {
+			return question{}, fieldError{"weight", fmt.Errorf("weight must not be negative, got %g", *r.Weight)}
+		}
+		q.weight = *r.Weight
+	}
+	if q.category == "" {
+		q.category = defaults.Category
+	}
+	for _, tag := range r.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			q.tags = append(q.tags, tag)
+		}
+	}
+
+	switch kind {
+	case textQuestion:
+		matchSpec := r.Match
+		if matchSpec == "" {
+			matchSpec = defaults.Match
+		}
+		// The answers to template questions are always numbers.
+		if matchSpec == "" && isTemplate {
+			matchSpec = matchNumeric
+		}
+		match, err := parseMatchRule(matchSpec)
+		if err != nil {
+			return question{}, fieldError{"match", err}
+		}
+		if isTemplate {
+			count := 1
+			if r.Count != nil {
+				count = *r.Count
+			}
+			q.template, err = parseQuestionTemplate(*r.Question, q.hint, r.Expr, r.Vars, count)
+			if err != nil {
+				return question{}, err
+			}
+			q.match = match
+			break
+		}
+		if err := match.validate(answers); err != nil {
+			return question{}, fieldError{r.answerField(), err}
+		}
+		q.answers = answers
+		q.match = match
+
+	case choiceQuestion:
+		options, err := r.choiceOptions(answers)
+		if err != nil {
+			return question{}, err
+		}
+		q.options = options
+
+	case trueFalseQuestion:
+		if len(answers) != 1 {
+			return question{}, fieldError{r.answerField(), fmt.Errorf("%s questions must have exactly one answer, got %d", kind, len(answers))}
+		}
+		answer, ok := parseTrueFalse(answers[0])
+		if !ok {
+			return question{}, fieldError{r.answerField(), fmt.Errorf("answer %q is not true or false", answers[0])}
+		}
+		q.answers = []string{strconv.FormatBool(answer)}
+
+	default:
+		return question{}, fieldError{"type", fmt.Errorf("unknown question type %q", r.Type)}
+	}
+	return q, nil
+}
+
+// choiceOptions returns the options of a multiple choice question where the correct ones are referred to by their
+// letters in answers.
+func (r questionRecord) choiceOptions(answers []string) ([]option, error) {
+	if len(r.Options) < 2 || len(r.Options) > 26 {
+		return nil, fieldError{"options", fmt.Errorf("%s questions must have between 2 and 26 options, got %d", choiceQuestion, len(r.Options))}
+	}
+	options := make([]option, len(r.Options))
+	for i, text := range r.Options {
+		options[i].text = text
+	}
+	for _, answer := range answers {
+		i, ok := optionIndex(answer, len(options))
+		if !ok {
+			return nil, fieldError{r.answerField(), fmt.Errorf("answer %q is not the letter of one of the %d options", answer, len(options))}
+		}
+		options[i].correct = true
+	}
+	return options, nil
+}
+
+// csvLoader loads questions from CSV where each row is of the form: question,answer
+//
+// If the first row is a header starting with the question column, then the columns are instead named by the header.
+// The recognised columns are question, answer, answers (several accepted answers separated by |), match, type, options
+// (the options of a multiple choice question separated by |), category, tags (separated by |), weight and hint.
+// File-wide defaults can be set by comment lines at the top of the file of the form:
+//
+//	# match: nocase
+//	# category: arithmetic
+type csvLoader struct{}
+
+var csvColumns = []string{"question", "answer", "answers", "match", "type", "options", "category", "tags", "weight", "hint", "vars", "expr", "count"}
+
+func (l csvLoader) Load(r io.Reader) ([]question, error) {
+	b, err := l.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return b.questions()
+}
+
+func (csvLoader) Parse(r io.Reader) (bank, error) {
+	var b bank
+	reader := bufio.NewReader(r)
+	directiveLines, err := parseCSVDirectives(reader, &b.defaults)
+	if err != nil {
+		return bank{}, err
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+
+	var columns []string
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var csvErr *csv.ParseError
+			if errors.As(err, &csvErr) {
+				return bank{}, parseError{
+					pos: position{line: csvErr.Line + directiveLines, column: csvErr.Column},
+					msg: csvErr.Err.Error(),
+				}
+			}
+			return bank{}, fmt.Errorf("parse csv: %s", err)
+		}
+
+		positions := make([]position, len(row))
+		for i := range row {
+			line, column := csvReader.FieldPos(i)
+			positions[i] = position{line: line + directiveLines, column: column}
+		}
+
+		if columns == nil {
+			if strings.EqualFold(strings.TrimSpace(row[0]), "question") {
+				columns, err = parseCSVHeader(row)
+				if err != nil {
+					return bank{}, lineErrorf(positions[0].line, "%s", err)
+				}
+				continue
+			}
+			columns = []string{"question", "answer"}
+		}
+
+		record := csvRecord(columns, row, positions)
+		if len(row) < 2 {
+			record.err = fmt.Errorf("expected at least 2 fields (question, answer), got %d", len(row))
+		}
+		b.records = append(b.records, record)
+	}
+	return b, nil
+}
+
+// parseCSVDirectives reads the comment lines at the start of a CSV problems file into defaults and returns the number
+// of lines read. Only comments which start with a known key followed by a colon, like "# match: nocase", are directives
+// and any others are ignored, so that ordinary comments like "# Source: wikipedia" are still allowed.
+func parseCSVDirectives(reader *bufio.Reader, defaults *bankDefaults) (int, error) {
+	lines := 0
+	for {
+		next, err := reader.Peek(1)
+		if err != nil || next[0] != '#' {
+			return lines, nil
+		}
+		directive, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return 0, fmt.Errorf("read csv: %s", err)
+		}
+		lines++
+
+		key, value, found := strings.Cut(strings.TrimPrefix(strings.TrimSpace(directive), "#"), ":")
+		if !found {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "match":
+			defaults.Match = strings.TrimSpace(value)
+		case "category":
+			defaults.Category = strings.TrimSpace(value)
+		}
+	}
+}
+
+func parseCSVHeader(row []string) ([]string, error) {
+	columns := make([]string, len(row))
+	seen := map[string]bool{}
+	for i, field := range row {
+		column := strings.ToLower(strings.TrimSpace(field))
+		known := false
+		for _, c := range csvColumns {
+			known = known || c == column
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown column %q", field)
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("duplicate column %q", field)
+		}
+		seen[column] = true
+		columns[i] = column
+	}
+	return columns, nil
+}
+
+func csvRecord(columns []string, row []string, positions []position) questionRecord {
+	record := questionRecord{pos: positions[0], fieldPos: map[string]position{}}
+	for i, column := range columns {
+		if i >= len(row) {
+			break
+		}
+		field := row[i]
+		record.fieldPos[column] = positions[i]
+		switch column {
+		case "question":
+			record.Question = &field
+		case "answer":
+			record.Answer = &field
+		case "answers":
+			if field != "" {
+				record.Answers = strings.Split(field, "|")
+			}
+		case "match":
+			record.Match = field
+		case "type":
+			record.Type = field
+		case "options":
+			if field != "" {
+				record.Options = strings.Split(field, "|")
+			}
+		case "category":
+			record.Category = field
+		case "tags":
+			if field != "" {
+				record.Tags = strings.Split(field, "|")
+			}
+		case "weight":
+			if strings.TrimSpace(field) == "" {
+				break
+			}
+			weight, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil {
+				record.err = fieldError{"weight", fmt.Errorf("weight %q is not a number", field)}
+				break
+			}
+			record.Weight = &weight
+		case "hint":
+			record.Hint = field
+		case "vars":
+			if strings.TrimSpace(field) == "" {
+				break
+			}
+			// Variables are written as name=spec, separated by |, like a=1..10|b=2,3,5.
+			record.Vars = map[string]string{}
+			for _, v := range strings.Split(field, "|") {
+				name, spec, ok := strings.Cut(v, "=")
+				if !ok {
+					record.err = fieldError{"vars", fmt.Errorf("variable %q is not of the form name=values", v)}
+					break
+				}
+				record.Vars[strings.TrimSpace(name)] = spec
+			}
+		case "expr":
+			record.Expr = field
+		case "count":
+			if strings.TrimSpace(field) == "" {
+				break
+			}
+			count, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				record.err = fieldError{"count", fmt.Errorf("count %q is not an integer", field)}
+				break
+			}
+			record.Count = &count
+		}
+	}
+	return record
+}
+
+// jsonLoader loads questions from a JSON array of objects with question and answer fields. File-wide defaults can be
+// set by instead providing an object with the questions under the questions key:
+//
+//	{"match": "nocase", "questions": [...]}
+type jsonLoader struct{}
+
+func (l jsonLoader) Load(r io.Reader) ([]question, error) {
+	b, err := l.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return b.questions()
+}
+
+func (jsonLoader) Parse(r io.Reader) (bank, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return bank{}, fmt.Errorf("read json: %s", err)
+	}
+
+	var b bank
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	token, err := decoder.Token()
+	if err != nil {
+		return bank{}, jsonError(data, 0, err)
+	}
+	switch token {
+	case json.Delim('['):
+		if b.records, err = decodeJSONRecords(data, decoder); err != nil {
+			return bank{}, err
+		}
+	case json.Delim('{'):
+		for decoder.More() {
+			offset := skipSeparators(data, decoder.InputOffset())
+			key, err := decoder.Token()
+			if err != nil {
+				return bank{}, jsonError(data, 0, err)
+			}
+			switch key {
+			case "match":
+				if err := decoder.Decode(&b.defaults.Match); err != nil {
+					return bank{}, jsonError(data, 0, err)
+				}
+			case "category":
+				if err := decoder.Decode(&b.defaults.Category); err != nil {
+					return bank{}, jsonError(data, 0, err)
+				}
+			case "questions":
+				if token, err := decoder.Token(); err != nil {
+					return bank{}, jsonError(data, 0, err)
+				} else if token != json.Delim('[') {
+					return bank{}, lineErrorf(lineAt(data, offset), "expected questions to be an array")
+				}
+				if b.records, err = decodeJSONRecords(data, decoder); err != nil {
+					return bank{}, err
+				}
+			default:
+				return bank{}, lineErrorf(lineAt(data, offset), "unknown key %q", key)
+			}
+		}
+		if _, err := decoder.Token(); err != nil {
+			return bank{}, jsonError(data, 0, err)
+		}
+	default:
+		return bank{}, lineErrorf(lineAt(data, 0), "expected an array of questions")
+	}
+	return b, nil
+}
+
+// decodeJSONRecords decodes the elements of the array which decoder has just read the opening bracket of, up to and
+// including the closing bracket.
+func decodeJSONRecords(data []byte, decoder *json.Decoder) ([]questionRecord, error) {
+	var records []questionRecord
+	for decoder.More() {
+		start := skipSeparators(data, decoder.InputOffset())
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, jsonError(data, 0, err)
+		}
+		record := questionRecord{pos: positionAt(data, start), fieldPos: map[string]position{}}
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return nil, jsonError(data, start, err)
+		}
+		for field, offset := range jsonFieldOffsets(raw) {
+			record.fieldPos[field] = positionAt(data, start+offset)
+		}
+		records = append(records, record)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, jsonError(data, 0, err)
+	}
+	return records, nil
+}
+
+// jsonFieldOffsets returns the offset of the value of each top-level field in the JSON object raw.
+func jsonFieldOffsets(raw []byte) map[string]int64 {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil
+	}
+	offsets := map[string]int64{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return offsets
+		}
+		field, _ := token.(string)
+		offsets[field] = skipSeparators(raw, decoder.InputOffset())
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return offsets
+		}
+	}
+	return offsets
+}
+
+// jsonError adds the line number of the error to err if it has an offset. base is the offset in data of the JSON which
+// the error occurred in.
+func jsonError(data []byte, base int64, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return parseError{pos: positionAt(data, base+syntaxErr.Offset), msg: err.Error()}
+	case errors.As(err, &typeErr):
+		return parseError{pos: positionAt(data, base+typeErr.Offset), msg: err.Error()}
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		return lineErrorf(lineAt(data, int64(len(data))), "unexpected end of json")
+	default:
+		return fmt.Errorf("parse json: %s", err)
+	}
+}
+
+// skipSeparators returns the offset of the first byte in data at or after offset which is not whitespace, a comma or a
+// colon.
+func skipSeparators(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// lineAt returns the 1-indexed line number of the byte at offset in data.
+func lineAt(data []byte, offset int64) int {
+	return positionAt(data, offset).line
+}
+
+// positionAt returns the position of the byte at offset in data.
+func positionAt(data []byte, offset int64) position {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+	return position{
+		line:   bytes.Count(before, []byte("\n")) + 1,
+		column: len(before) - lineStart + 1,
+	}
+}
+
+// yamlLoader loads questions from a YAML sequence of mappings with question and answer keys. File-wide defaults can be
+// set by instead providing a mapping with the questions under the questions key:
+//
+//	match: nocase
+//	questions:
+//	  - question: ...
+type yamlLoader struct{}
+
+func (l yamlLoader) Load(r io.Reader) ([]question, error) {
+	b, err := l.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return b.questions()
+}
+
+// yamlSyntaxErrorRegex matches the message of a YAML syntax error, which the yaml package only gives the line of as part
+// of its message.
+var yamlSyntaxErrorRegex = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// yamlError adds the line number of the error to err if its message has one.
+func yamlError(err error) error {
+	match := yamlSyntaxErrorRegex.FindStringSubmatch(err.Error())
+	if match == nil {
+		return fmt.Errorf("parse yaml: %s", err)
+	}
+	line, _ := strconv.Atoi(match[1])
+	return lineErrorf(line, "%s", match[2])
+}
+
+func (yamlLoader) Parse(r io.Reader) (bank, error) {
+	var document yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&document); err != nil {
+		if err == io.EOF {
+			return bank{}, nil
+		}
+		return bank{}, yamlError(err)
+	}
+
+	var b bank
+	questionsNode := document.Content[0]
+	if questionsNode.Kind == yaml.MappingNode {
+		var file struct {
+			bankDefaults `yaml:",inline"`
+			Questions    yaml.Node `yaml:"questions"`
+		}
+		// Unknown keys are rejected in the same way as they are in JSON problems files.
+		for i := 0; i < len(questionsNode.Content); i += 2 {
+			keyNode := questionsNode.Content[i]
+			switch keyNode.Value {
+			case "match", "category", "questions":
+			default:
+				return bank{}, lineErrorf(keyNode.Line, "unknown key %q", keyNode.Value)
+			}
+		}
+		if err := questionsNode.Decode(&file); err != nil {
+			return bank{}, lineErrorf(questionsNode.Line, "%s", err)
+		}
+		b.defaults = file.bankDefaults
+		questionsNode = &file.Questions
+	}
+	if questionsNode.Kind != yaml.SequenceNode {
+		return bank{}, lineErrorf(questionsNode.Line, "expected a sequence of questions")
+	}
+
+	for _, node := range questionsNode.Content {
+		record := questionRecord{pos: position{line: node.Line, column: node.Column}, fieldPos: map[string]position{}}
+		if err := node.Decode(&record); err != nil {
+			return bank{}, lineErrorf(node.Line, "%s", err)
+		}
+		if node.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				value := node.Content[i+1]
+				record.fieldPos[node.Content[i].Value] = position{line: value.Line, column: value.Column}
+			}
+		}
+		b.records = append(b.records, record)
+	}
+	return b, nil
+}
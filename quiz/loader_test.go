@@ -0,0 +1,191 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoaders(t *testing.T) {
+	testCases := []struct {
+		name   string
+		loader questionLoader
+		input  string
+		want   []question
+	}{
+		{
+			name:   "csv",
+			loader: csvLoader{},
+			input:  "5+5,10\n1+1,2\n",
+			want:   []question{newTestQuestion("5+5", "10"), newTestQuestion("1+1", "2")},
+		},
+		{
+			name:   "csv with header and match directive",
+			loader: csvLoader{},
+			input:  "# match: nocase\nquestion,answers,match\nCapital of France?,paris|Paris,\n0.1+0.2,0.3,numeric:0.001\n",
+			want: []question{
+				{kind: textQuestion, question: "Capital of France?", answers: []string{"paris", "Paris"}, match: matchRule{mode: matchCaseInsensitive}, weight: 1},
+				{kind: textQuestion, question: "0.1+0.2", answers: []string{"0.3"}, match: matchRule{mode: matchNumeric, tolerance: 0.001}, weight: 1},
+			},
+		},
+		{
+			name:   "csv with plain comments",
+			loader: csvLoader{},
+			input:  "# Source: wikipedia\n# match: nocase\n# Last checked 2024\nCapital of France?,Paris\n",
+			want:   []question{{kind: textQuestion, question: "Capital of France?", answers: []string{"Paris"}, match: matchRule{mode: matchCaseInsensitive}, weight: 1}},
+		},
+		{
+			name:   "json",
+			loader: jsonLoader{},
+			input:  `[{"question": "5+5", "answer": "10"}, {"question": "1+1", "answer": "2"}]`,
+			want:   []question{newTestQuestion("5+5", "10"), newTestQuestion("1+1", "2")},
+		},
+		{
+			name:   "json object with defaults",
+			loader: jsonLoader{},
+			input:  `{"match": "whitespace", "questions": [{"question": "Say hi", "answers": ["hello world", "hi"]}]}`,
+			want:   []question{{kind: textQuestion, question: "Say hi", answers: []string{"hello world", "hi"}, match: matchRule{mode: matchWhitespace}, weight: 1}},
+		},
+		{
+			name:   "yaml",
+			loader: yamlLoader{},
+			input:  "- question: 5+5\n  answer: 10\n- question: 1+1\n  answer: 2\n",
+			want:   []question{newTestQuestion("5+5", "10"), newTestQuestion("1+1", "2")},
+		},
+		{
+			name:   "yaml mapping with defaults",
+			loader: yamlLoader{},
+			input:  "match: regex\nquestions:\n  - question: Colour?\n    answer: colou?r\n",
+			want:   []question{{kind: textQuestion, question: "Colour?", answers: []string{"colou?r"}, match: matchRule{mode: matchRegex}, weight: 1}},
+		},
+		{
+			name:   "yaml multiple choice and true/false",
+			loader: yamlLoader{},
+			input:  "- question: Primes?\n  type: choice\n  options: [2, 4, 5]\n  answers: [a, c]\n- question: Go is compiled\n  type: truefalse\n  answer: yes\n",
+			want: []question{
+				{kind: choiceQuestion, question: "Primes?", options: []option{{"2", true}, {"4", false}, {"5", true}}, weight: 1},
+				{kind: trueFalseQuestion, question: "Go is compiled", answers: []string{"true"}, weight: 1},
+			},
+		},
+		{
+			name:   "csv multiple choice",
+			loader: csvLoader{},
+			input:  "question,answer,type,options\nLargest?,b,choice,1|3|2\n",
+			want:   []question{{kind: choiceQuestion, question: "Largest?", options: []option{{"1", false}, {"3", true}, {"2", false}}, weight: 1}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.loader.Load(strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatalf("Load(%q) returned unexpected err: %s", tc.input, err)
+			}
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("Load(%q) = %v, want %v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadersReturnLineNumberedErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		loader  questionLoader
+		input   string
+		wantErr string
+	}{
+		{
+			name:    "csv row with one field",
+			loader:  csvLoader{},
+			input:   "5+5,10\n7+3\n",
+			wantErr: "line 2: expected at least 2 fields (question, answer), got 1",
+		},
+		{
+			name:    "csv answer which is not a number",
+			loader:  csvLoader{},
+			input:   "# match: numeric\nquestion,answer\n5+5,10\n7+3,ten\n",
+			wantErr: `line 4: answer "ten" is not a number`,
+		},
+		{
+			name:    "json choice answer which is not an option letter",
+			loader:  jsonLoader{},
+			input:   "[\n  {\"question\": \"Largest?\", \"type\": \"choice\", \"options\": [\"1\", \"2\"], \"answer\": \"c\"}\n]",
+			wantErr: `line 2: answer "c" is not the letter of one of the 2 options`,
+		},
+		{
+			name:    "json object missing answer",
+			loader:  jsonLoader{},
+			input:   "[\n  {\"question\": \"5+5\", \"answer\": \"10\"},\n  {\"question\": \"7+3\"}\n]",
+			wantErr: "line 3: missing answer field",
+		},
+		{
+			name:    "json syntax error",
+			loader:  jsonLoader{},
+			input:   "[\n  {\"question\": \"5+5\",\n  \"answer\" 10}\n]",
+			wantErr: "line 3: ",
+		},
+		{
+			name:    "json object with unknown key",
+			loader:  jsonLoader{},
+			input:   "{\n  \"match\": \"nocase\",\n  \"mtach\": \"exact\",\n  \"questions\": []\n}",
+			wantErr: `line 3: unknown key "mtach"`,
+		},
+		{
+			name:    "yaml mapping with unknown key",
+			loader:  yamlLoader{},
+			input:   "match: nocase\nmtach: exact\nquestions: []\n",
+			wantErr: `line 2: unknown key "mtach"`,
+		},
+		{
+			name:    "yaml mapping missing question",
+			loader:  yamlLoader{},
+			input:   "- question: 5+5\n  answer: 10\n- answer: 10\n",
+			wantErr: "line 3: missing question field",
+		},
+		{
+			name:    "yaml syntax error",
+			loader:  yamlLoader{},
+			input:   "- question: 5+5\n  answer: 10\n- question: 7+3\n  answer: 10: 11\n",
+			wantErr: "line 4: mapping values are not allowed in this context",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.loader.Load(strings.NewReader(tc.input))
+			if err == nil || !strings.HasPrefix(err.Error(), tc.wantErr) {
+				t.Errorf("Load(%q) returned err: %v, want err starting with %q", tc.input, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadQuestionsMergesDirectory(t *testing.T) {
+	dir := t.TempDir()
+	mustWriteFile(t, filepath.Join(dir, "a.csv"), "5+5,10\n")
+	mustWriteFile(t, filepath.Join(dir, "b.yaml"), "- question: 1+1\n  answer: 2\n")
+	mustWriteFile(t, filepath.Join(dir, "README.md"), "not a problems file")
+
+	got, err := readQuestions(dir, "")
+	if err != nil {
+		t.Fatalf("readQuestions(%q, \"\") returned unexpected err: %s", dir, err)
+	}
+
+	want := []question{newTestQuestion("5+5", "10"), newTestQuestion("1+1", "2")}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("readQuestions(%q, \"\") = %v, want %v", dir, got, want)
+	}
+}
+
+func mustWriteFile(t *testing.T, path string, contents string) {
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("failed to write %s: %s", path, err)
+	}
+}
+
+func newTestQuestion(text string, answers ...string) question {
+	return question{kind: textQuestion, question: text, answers: answers, match: matchRule{mode: matchExact}, weight: 1}
+}
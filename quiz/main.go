@@ -1,109 +1,570 @@
 package main
 
 import (
-	"bufio"
-	"encoding/csv"
 	"flag"
 	"fmt"
+	"io"
+	"math/rand"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
 	"time"
 )
 
-var problemsFilePath = flag.String("problems", "test_data/problems.csv", "problems csv file")
-var timeout = flag.Duration("timeout", 30*time.Second, "time limit for all questions to be answered within")
+var problemsFilePath = flag.String("problems", "test_data/problems.csv", "problems file or directory of problems files")
+var format = flag.String("format", "", "format of the problems files: csv, json or yaml (default inferred from file extension)")
+var timeout = flag.Duration("timeout", 30*time.Second, "time limit for all questions to be answered within (0 for no limit)")
+var perQuestion = flag.Duration("per-question", 0, "time limit for each question to be answered within (0 for no limit)")
+var shuffleOptions = flag.Bool("shuffle-options", false, "shuffle the options of multiple choice questions")
+var generate = flag.String("generate", "", "generate arithmetic questions using a comma separated list of operators (add, sub, mul, div) instead of reading a problems file")
+var count = flag.Int("count", 20, "number of questions to generate when -generate is set")
+var difficulty = flag.Int("difficulty", 1, "difficulty of generated questions from 1 to 5 when -generate is set")
+var seed = flag.Int64("seed", 0, "seed for generating, sampling and shuffling questions (default random)")
+var category = flag.String("category", "", "only ask the questions in this category")
+var tags = flag.String("tags", "", "only ask the questions which have any of this comma separated list of tags")
+var sample = flag.Int("sample", 0, "ask a random sample of this many questions (0 for all questions)")
+var shuffle = flag.Bool("shuffle", false, "ask the questions in a random order")
+var serve = flag.Bool("serve", false, "serve the quiz over HTTP instead of running it in the terminal")
+var port = flag.Uint("port", 8080, "port to serve on when -serve or -host is set")
+var hostGame = flag.Bool("host", false, "host a multiplayer quiz which players join over TCP")
+var players = flag.Int("players", 2, "number of players to wait for before starting when -host is set")
+var firstCorrect = flag.Bool("first-correct", false, "end each question once it's answered correctly when -host is set, so only the first correct answer scores")
+var join = flag.String("join", "", "join the multiplayer quiz hosted at this address")
+var playerName = flag.String("name", os.Getenv("USER"), "name to join a multiplayer quiz with")
+var historyFile = flag.String("history", "", fmt.Sprintf(`file that attempts are recorded to (default "~/%s/%s")`, configDir, defaultHistoryFile))
+var record = flag.Bool("record", true, "record the attempt to the history file")
+var practice = flag.Bool("practice", false, "only ask the questions which are due for review according to the history file, hardest first")
+var practiceLimit = flag.Int("practice-limit", 20, "maximum number of questions to ask when -practice is set (0 for no limit)")
+var output = flag.String("output", "text", "format of the results: text, json or junit")
+var passMark = flag.Float64("pass-mark", 0, "percentage score needed to pass, the exit code is 2 if the quiz is failed")
+var hintPenalty = flag.Float64("hint-penalty", 0.5, "fraction of a question's weight which is lost by asking for its hint with ?")
+var stateFile = flag.String("state", defaultStateFile, "file that the quiz is saved to when it's suspended with :save or Ctrl-C")
+var resume = flag.String("resume", "", "resume the quiz which was saved to this state file")
+var stats = flag.Bool("stats", false, "report statistics from the history file instead of running the quiz")
 
 func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n  quiz [flags]\n  quiz [flags] lint <problems file or directory>...\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
-	if err := runQuiz(*problemsFilePath, *timeout); err != nil {
+
+	if flag.Arg(0) == "lint" {
+		numIssues, err := lintPaths(flag.Args()[1:], *format, os.Stdout)
+		if err != nil {
+			fmt.Println(fmt.Errorf("Error occurred: %s", err))
+			os.Exit(1)
+		}
+		if numIssues > 0 {
+			fmt.Printf("Found %d issues\n", numIssues)
+			os.Exit(1)
+		}
+		return
+	}
+
+	historyPath, err := resolveHistoryPath(*historyFile)
+	if err != nil {
 		fmt.Println(fmt.Errorf("Error occurred: %s", err))
 		os.Exit(1)
 	}
-}
 
-func runQuiz(problemsFilePath string, timeout time.Duration) error {
-	questions, err := readQuestions(problemsFilePath)
-	if err != nil {
-		return fmt.Errorf("read questions: %s", err)
+	config := quizConfig{
+		problemsPath:       *problemsFilePath,
+		format:             *format,
+		timeout:            *timeout,
+		perQuestionTimeout: *perQuestion,
+		shuffleOptions:     *shuffleOptions,
+		output:             *output,
+		passMark:           *passMark,
+		hintPenalty:        *hintPenalty,
+		category:           *category,
+		sample:             *sample,
+		shuffle:            *shuffle,
+		seed:               *seed,
+		statePath:          *stateFile,
+		resumePath:         *resume,
+	}
+	if config.resumePath != "" && !flagSet("state") {
+		config.statePath = config.resumePath
+	}
+	if !flagSet("seed") {
+		config.seed = time.Now().UnixNano()
+	}
+	if *tags != "" {
+		for _, tag := range strings.Split(*tags, ",") {
+			config.tags = append(config.tags, strings.TrimSpace(tag))
+		}
+	}
+	if _, ok := formatToReportWriter[config.output]; !ok {
+		fmt.Println(fmt.Errorf("Error occurred: unsupported -output %q, expected text, json or junit", config.output))
+		os.Exit(1)
+	}
+	if *generate != "" {
+		operators, err := parseOperators(*generate)
+		if err != nil {
+			fmt.Println(fmt.Errorf("Error occurred: parse -generate: %s", err))
+			os.Exit(1)
+		}
+		config.generator = &generatorConfig{
+			operators:  operators,
+			count:      *count,
+			difficulty: *difficulty,
+			seed:       config.seed,
+		}
+	}
+	if *record {
+		config.historyPath = historyPath
+	}
+	if *practice {
+		config.practiceHistoryPath = historyPath
+		config.practiceLimit = *practiceLimit
 	}
 
-	_, err = input("Press enter to start")
+	passed := true
+	switch {
+	case *stats:
+		bank := ""
+		if flagSet("problems") || flagSet("generate") {
+			bank = config.bankName()
+		}
+		err = reportStats(historyPath, bank, os.Stdout)
+	case *serve:
+		err = serveQuiz(config, *port)
+	case *hostGame:
+		err = hostQuiz(config, hostConfig{players: *players, firstCorrect: *firstCorrect}, *port, os.Stdout)
+	case *join != "":
+		err = joinQuiz(*join, *playerName, os.Stdin, os.Stdout)
+	default:
+		// Only the results are written to stdout when they're machine-readable so that they can be parsed.
+		out := io.Writer(os.Stdout)
+		if config.output != "text" {
+			out = os.Stderr
+		}
+		passed, err = runQuiz(config, os.Stdin, out, os.Stdout)
+	}
 	if err != nil {
-		return fmt.Errorf("input: %s", err)
+		fmt.Println(fmt.Errorf("Error occurred: %s", err))
+		os.Exit(1)
 	}
+	if !passed {
+		os.Exit(2)
+	}
+}
 
-	score, err := askQuestions(questions, timeout)
+// quizConfig holds the options which a quiz is run with.
+type quizConfig struct {
+	problemsPath string
+	format       string
+	// generator describes the questions to generate instead of reading them from problemsPath, if it's not nil.
+	generator          *generatorConfig
+	timeout            time.Duration
+	perQuestionTimeout time.Duration
+	shuffleOptions     bool
+	// category and tags filter the questions which are asked to those in category and with any of tags, if they're
+	// not empty.
+	category string
+	tags     []string
+	// sample is the number of questions to randomly choose to ask or 0 if all of them should be asked.
+	sample  int
+	shuffle bool
+	// seed seeds the random choices made when preparing the questions, so the same seed asks the same questions.
+	seed int64
+	// output is the format that the results are written in.
+	output string
+	// passMark is the percentage of the weighted score which must be reached to pass.
+	passMark float64
+	// hintPenalty is the fraction of a question's weight which is lost by using its hint.
+	hintPenalty float64
+	// historyPath is the file that the attempt is recorded to or empty if it shouldn't be recorded.
+	historyPath string
+	// practiceHistoryPath is the history file that the questions which are due for review are worked out from when
+	// practicing or empty if all questions should be asked.
+	practiceHistoryPath string
+	practiceLimit       int
+	// statePath is the file that the quiz is saved to when it's suspended.
+	statePath string
+	// resumePath is the state file of the suspended quiz to resume or empty if a new quiz should be started.
+	resumePath string
+}
+
+// loadQuestions returns the questions which the quiz described by config asks, before they're prepared for a
+// particular run of it. Template questions are expanded using config.seed.
+func loadQuestions(config quizConfig) ([]question, error) {
+	if config.generator != nil {
+		questions, err := generateQuestions(*config.generator)
+		if err != nil {
+			return nil, fmt.Errorf("generate questions: %s", err)
+		}
+		return filterQuestions(indexQuestions(questions), config)
+	}
+	questions, err := readQuestions(config.problemsPath, config.format)
 	if err != nil {
-		return fmt.Errorf("ask questions: %s", err)
+		return nil, fmt.Errorf("read questions: %s", err)
 	}
+	questions, err = expandTemplates(questions, rand.New(rand.NewSource(config.seed)))
+	if err != nil {
+		return nil, err
+	}
+	return filterQuestions(indexQuestions(questions), config)
+}
 
-	fmt.Printf("Score: %d / %d\n", score, len(questions))
+// indexQuestions sets the index of each of questions to its position in the bank before it's filtered.
+func indexQuestions(questions []question) []question {
+	for i := range questions {
+		questions[i].index = i
+	}
+	return questions
+}
 
-	return nil
+// filterQuestions returns the questions which are in config.category and have any of config.tags.
+func filterQuestions(questions []question, config quizConfig) ([]question, error) {
+	if config.category == "" && len(config.tags) == 0 {
+		return questions, nil
+	}
+	var filtered []question
+	for _, q := range questions {
+		if config.category != "" && !strings.EqualFold(q.category, config.category) {
+			continue
+		}
+		if len(config.tags) > 0 && !q.hasAnyTag(config.tags) {
+			continue
+		}
+		filtered = append(filtered, q)
+	}
+	if len(filtered) == 0 {
+		return nil, fmt.Errorf("none of the %d questions match the given category and tags", len(questions))
+	}
+	return filtered, nil
 }
 
-type question struct {
-	question, answer string
+// bankName returns the name that attempts at the quiz are recorded under in the history file.
+func (c quizConfig) bankName() string {
+	if c.generator != nil {
+		return c.generator.String()
+	}
+	return bankName(c.problemsPath)
 }
 
-func readQuestions(filePath string) ([]question, error) {
-	f, err := os.Open(*problemsFilePath)
+// flagSet reports whether the flag with the given name was set on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+// runQuiz runs the quiz described by config, reading answers from in and writing questions to out. The results are
+// written to reportOut in the format given by config.output and whether the quiz was passed is returned.
+func runQuiz(config quizConfig, in io.Reader, out io.Writer, reportOut io.Writer) (bool, error) {
+	var saved *savedQuiz
+	if config.resumePath != "" {
+		s, err := readSavedQuiz(config.resumePath)
+		if err != nil {
+			return false, err
+		}
+		saved = &s
+		config = saved.apply(config)
+	}
+
+	questions, err := loadQuestions(config)
 	if err != nil {
-		return nil, fmt.Errorf("open problems file: %s", err)
+		return false, err
 	}
 
-	csvReader := csv.NewReader(f)
-	records, err := csvReader.ReadAll()
+	result := quizResult{hintPenalty: config.hintPenalty}
+	started := time.Now()
+	var previouslyElapsed time.Duration
+	startMsg := "Press enter to start"
+	if saved != nil {
+		questions, result, err = saved.restore(questions, config.hintPenalty)
+		if err != nil {
+			return false, fmt.Errorf("restore saved quiz: %s", err)
+		}
+		started = saved.Started
+		previouslyElapsed = time.Duration(saved.ElapsedMS) * time.Millisecond
+		startMsg = fmt.Sprintf("Resuming from question %d of %d, press enter to continue", len(result.answers)+1, len(questions))
+	} else {
+		if config.practiceHistoryPath != "" {
+			attempts, err := readAttempts(config.practiceHistoryPath, config.bankName())
+			if err != nil {
+				return false, fmt.Errorf("read attempts: %s", err)
+			}
+			var nextDue time.Time
+			questions, nextDue = dueQuestions(questions, leitnerStates(attempts), time.Now(), config.practiceLimit)
+			if len(questions) == 0 {
+				fmt.Fprintf(out, "No questions are due for review, the next is due at %s\n", nextDue.Local().Format("2006-01-02 15:04"))
+				return true, nil
+			}
+			if config.historyPath == "" {
+				fmt.Fprintln(out, "Warning: this practice session won't be recorded so it won't affect when questions are next due")
+			}
+		}
+
+		questions = prepareQuestions(questions, config, rand.New(rand.NewSource(config.seed)))
+	}
+
+	console := newConsole(in, out)
+	defer console.Close()
+
+	if _, err := console.Input(startMsg); err != nil {
+		return false, fmt.Errorf("input: %s", err)
+	}
+	if saved == nil {
+		started = time.Now()
+	}
+
+	// Ctrl-C suspends the quiz instead of killing it while questions are being asked.
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	resumed := time.Now()
+	result, err = askQuestions(questions, console, config, result, interrupts)
+	signal.Stop(interrupts)
 	if err != nil {
-		return nil, fmt.Errorf("parse problems file: %s", err)
+		return false, fmt.Errorf("ask questions: %s", err)
+	}
+	elapsed := previouslyElapsed + time.Since(resumed)
+
+	if result.suspended {
+		if err := writeSavedQuiz(config.statePath, newSavedQuiz(config, questions, result, started, elapsed)); err != nil {
+			return false, fmt.Errorf("save quiz: %s", err)
+		}
+		fmt.Fprintf(out, "Quiz saved, resume it with -resume %s\n", config.statePath)
+		return true, nil
+	}
+
+	report := newQuizReport(config, questions, result, elapsed)
+	if err := formatToReportWriter[config.output](reportOut, report); err != nil {
+		return false, fmt.Errorf("write %s report: %s", config.output, err)
 	}
 
-	questions := make([]question, len(records))
-	for i, record := range records {
-		questions[i] = question{
-			question: record[0],
-			answer:   record[1],
+	if config.historyPath != "" {
+		attempt := newAttempt(started, config.bankName(), len(questions), result)
+		if err := appendAttempt(config.historyPath, attempt); err != nil {
+			return false, fmt.Errorf("record attempt: %s", err)
 		}
 	}
-	return questions, nil
+
+	// The quiz has been finished so the state file it was resumed from can't be resumed again.
+	if saved != nil {
+		if err := os.Remove(config.resumePath); err != nil && !os.IsNotExist(err) {
+			return false, fmt.Errorf("remove state file: %s", err)
+		}
+	}
+
+	return report.Passed, nil
 }
 
-func askQuestions(questions []question, timeLimit time.Duration) (int, error) {
-	timer := time.NewTimer(timeLimit)
-	score := 0
-	for _, question := range questions {
-		answerChan := make(chan string)
-		errChan := make(chan error)
-		go func() {
-			answer, err := input(question.question)
-			if err != nil {
-				errChan <- err
-				return
-			}
-			answerChan <- answer
-		}()
-
-		select {
-		case <-timer.C:
-			fmt.Printf("\nTimed out after %s\n", timeLimit)
-			return score, nil
-		case answer := <-answerChan:
-			if answer == question.answer {
-				score++
-			}
-		case err := <-errChan:
-			return 0, fmt.Errorf("input: %s", err)
+// prepareQuestions returns the questions in the form that they should be asked in a single run of the quiz. A sample
+// of the questions keeps them in their original order unless they're also shuffled.
+func prepareQuestions(questions []question, config quizConfig, rng *rand.Rand) []question {
+	prepared := make([]question, len(questions))
+	copy(prepared, questions)
+
+	if config.sample > 0 && config.sample < len(prepared) {
+		indexes := rng.Perm(len(prepared))[:config.sample]
+		sort.Ints(indexes)
+		sampled := make([]question, len(indexes))
+		for i, index := range indexes {
+			sampled[i] = prepared[index]
 		}
+		prepared = sampled
 	}
-	return score, nil
+
+	if config.shuffle {
+		rng.Shuffle(len(prepared), func(i, j int) {
+			prepared[i], prepared[j] = prepared[j], prepared[i]
+		})
+	}
+
+	if config.shuffleOptions {
+		for i, question := range prepared {
+			prepared[i] = question.shuffleOptions(rng)
+		}
+	}
+	return prepared
 }
 
-func input(msg string) (string, error) {
-	stdin := bufio.NewReader(os.Stdin)
-	fmt.Printf("%s ", msg)
-	input, err := stdin.ReadString('\n')
-	if err != nil {
-		return "", fmt.Errorf("read from stdin: %s", err)
+// quizResult is the outcome of asking the questions in a quiz.
+type quizResult struct {
+	// score is the number of questions which were answered correctly.
+	score int
+	// weightedScore is the sum of the points scored for each question, see givenAnswer.points.
+	weightedScore float64
+	answers       []givenAnswer
+	// outOfTime is whether the quiz ended because its time limit was reached.
+	outOfTime bool
+	// hintPenalty is the fraction of a question's weight which is lost by using its hint.
+	hintPenalty float64
+	// suspended is whether the quiz was suspended before it ended, in which case timeLeft is how much of its time limit
+	// was left.
+	suspended bool
+	timeLeft  time.Duration
+}
+
+// givenAnswer is the answer given to a single question and how long it took to give.
+type givenAnswer struct {
+	question question
+	// answered is whether an answer was given, rather than the question timing out or ending before it was answered.
+	answered bool
+	answer   string
+	correct  bool
+	// credit is the fraction of the question which was answered correctly.
+	credit   float64
+	hinted   bool
+	timedOut bool
+	elapsed  time.Duration
+	// points is the question's weight scaled by the credit given for the answer and reduced if the hint was used.
+	points float64
+}
+
+// addAnswer scores the answer given to q after elapsed time. hinted is whether the hint to q was used.
+func (r *quizResult) addAnswer(q question, answer string, elapsed time.Duration, hinted bool) {
+	given := givenAnswer{
+		question: q,
+		answered: true,
+		answer:   answer,
+		correct:  q.isCorrect(answer),
+		credit:   q.credit(answer),
+		hinted:   hinted,
+		elapsed:  elapsed,
+	}
+	given.points = q.weight * given.credit
+	if hinted {
+		given.points *= 1 - r.hintPenalty
+	}
+	if given.correct {
+		r.score++
+	}
+	r.weightedScore += given.points
+	r.answers = append(r.answers, given)
+}
+
+// addTimeout scores q as wrong because it wasn't answered within its time limit.
+func (r *quizResult) addTimeout(q question, elapsed time.Duration) {
+	r.answers = append(r.answers, givenAnswer{
+		question: q,
+		timedOut: true,
+		elapsed:  elapsed,
+	})
+}
+
+// addUnanswered scores q as wrong because the question ended before it was answered, without running out of time.
+func (r *quizResult) addUnanswered(q question, elapsed time.Duration) {
+	r.answers = append(r.answers, givenAnswer{
+		question: q,
+		elapsed:  elapsed,
+	})
+}
+
+// suspend marks the quiz as suspended after it has been running since started. The timer for the current question is
+// stopped if there is one.
+func (r quizResult) suspend(config quizConfig, started time.Time, questionTimer *time.Timer) quizResult {
+	if questionTimer != nil {
+		questionTimer.Stop()
+	}
+	r.suspended = true
+	if config.timeout > 0 {
+		r.timeLeft = config.timeout - time.Since(started)
+	}
+	return r
+}
+
+// timedOut returns the questions which weren't answered within their time limit.
+func (r quizResult) timedOut() []question {
+	var questions []question
+	for _, answer := range r.answers {
+		if answer.timedOut {
+			questions = append(questions, answer.question)
+		}
+	}
+	return questions
+}
+
+// hintRequest is the answer which asks for the hint to a question instead of answering it.
+const hintRequest = "?"
+
+// saveRequest is the answer which suspends the quiz so that it can be resumed later.
+const saveRequest = ":save"
+
+// askQuestions asks each question in turn on the console, carrying on from the answers already in result, and returns
+// the result. The quiz ends early if config.timeout is reached or the console runs out of input, and each question is
+// skipped and scored as wrong if it isn't answered within config.perQuestionTimeout. The next line of input after a
+// question is skipped is discarded, since it may be a late answer to that question. A zero time limit means that there
+// is no limit. Answering a question with ? shows its hint, which reduces the weighted score for the question by
+// config.hintPenalty. Answering with :save or receiving from interrupts suspends the quiz before the current question.
+func askQuestions(questions []question, console *console, config quizConfig, result quizResult, interrupts <-chan os.Signal) (quizResult, error) {
+	started := time.Now()
+	var quizTimeout <-chan time.Time
+	if config.timeout > 0 {
+		timer := time.NewTimer(config.timeout)
+		defer timer.Stop()
+		quizTimeout = timer.C
+	}
+
+	for _, question := range questions[len(result.answers):] {
+		console.Printf("%s ", question.prompt())
+		asked := time.Now()
+
+		var questionTimer *time.Timer
+		var questionTimeout <-chan time.Time
+		if config.perQuestionTimeout > 0 {
+			questionTimer = time.NewTimer(config.perQuestionTimeout)
+			questionTimeout = questionTimer.C
+		}
+
+		answered := false
+		hinted := false
+		// skipped is whether the question ran out of time and the line which it's waiting for is being discarded.
+		skipped := false
+		for !answered {
+			select {
+			case <-quizTimeout:
+				console.Printf("\nTimed out after %s\n", config.timeout)
+				result.outOfTime = true
+				return result, nil
+			case <-interrupts:
+				console.Printf("\n")
+				return result.suspend(config, started, questionTimer), nil
+			case <-questionTimeout:
+				// The answer may have been part way through being typed, so the next line is discarded instead of being
+				// taken as the answer to the next question.
+				console.Printf("\nOut of time for this question after %s, press enter to continue ", config.perQuestionTimeout)
+				result.addTimeout(question, time.Since(asked))
+				questionTimeout = nil
+				skipped = true
+			case answer := <-console.Answers():
+				if answer.err == io.EOF {
+					console.Printf("\nRan out of answers\n")
+					return result, nil
+				}
+				if answer.err != nil {
+					return quizResult{}, fmt.Errorf("input: %s", answer.err)
+				}
+				if answer.text == saveRequest {
+					return result.suspend(config, started, questionTimer), nil
+				}
+				if skipped {
+					answered = true
+					continue
+				}
+				if answer.text == hintRequest {
+					if question.hint == "" {
+						console.Printf("No hint available\n%s ", question.prompt())
+					} else {
+						hinted = true
+						console.Printf("Hint: %s\n%s ", question.hint, question.prompt())
+					}
+					continue
+				}
+				if questionTimer != nil {
+					questionTimer.Stop()
+				}
+				result.addAnswer(question, answer.text, time.Since(asked), hinted)
+				answered = true
+			}
+		}
 	}
-	return strings.TrimSpace(input), nil
+	return result, nil
 }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The modes which a matchRule can use to compare a given answer to an accepted one.
+const (
+	matchExact           = "exact"
+	matchCaseInsensitive = "nocase"
+	matchWhitespace      = "whitespace"
+	matchNumeric         = "numeric"
+	matchRegex           = "regex"
+)
+
+// matchRule decides whether an answer given to a question matches one of its accepted answers.
+type matchRule struct {
+	mode string
+	// tolerance is the largest difference allowed between a given and an accepted answer in numeric mode.
+	tolerance float64
+}
+
+// parseMatchRule parses a match rule of the form mode or, for numeric mode, numeric:tolerance. An empty string is
+// parsed as an exact match rule.
+func parseMatchRule(s string) (matchRule, error) {
+	mode, arg, hasArg := strings.Cut(strings.TrimSpace(s), ":")
+	mode = strings.ToLower(mode)
+	switch mode {
+	case "":
+		return matchRule{mode: matchExact}, nil
+	case matchExact, matchCaseInsensitive, matchWhitespace, matchRegex:
+		if hasArg {
+			return matchRule{}, fmt.Errorf("match mode %q does not take an argument", mode)
+		}
+		return matchRule{mode: mode}, nil
+	case matchNumeric:
+		rule := matchRule{mode: mode}
+		if hasArg {
+			tolerance, err := strconv.ParseFloat(arg, 64)
+			if err != nil || tolerance < 0 {
+				return matchRule{}, fmt.Errorf("numeric match tolerance %q is not a non-negative number", arg)
+			}
+			rule.tolerance = tolerance
+		}
+		return rule, nil
+	default:
+		return matchRule{}, fmt.Errorf("unknown match mode %q", mode)
+	}
+}
+
+// validate returns an error if any of the accepted answers can't be used with the rule, like an answer which isn't a
+// number in numeric mode.
+func (r matchRule) validate(accepted []string) error {
+	for _, answer := range accepted {
+		switch r.mode {
+		case matchNumeric:
+			if _, err := parseNumber(answer); err != nil {
+				return fmt.Errorf("answer %q is not a number", answer)
+			}
+		case matchRegex:
+			if _, err := compileAnswerRegex(answer); err != nil {
+				return fmt.Errorf("answer %q is not a valid regex: %s", answer, err)
+			}
+		}
+	}
+	return nil
+}
+
+// matches reports whether given matches the accepted answer.
+func (r matchRule) matches(given string, accepted string) bool {
+	switch r.mode {
+	case matchCaseInsensitive:
+		return strings.EqualFold(given, accepted)
+	case matchWhitespace:
+		return collapseWhitespace(given) == collapseWhitespace(accepted)
+	case matchNumeric:
+		givenNum, err := parseNumber(given)
+		if err != nil {
+			return false
+		}
+		acceptedNum, err := parseNumber(accepted)
+		if err != nil {
+			return false
+		}
+		return math.Abs(givenNum-acceptedNum) <= r.tolerance
+	case matchRegex:
+		re, err := compileAnswerRegex(accepted)
+		if err != nil {
+			return false
+		}
+		return re.MatchString(given)
+	default:
+		return given == accepted
+	}
+}
+
+func (r matchRule) String() string {
+	if r.mode == matchNumeric && r.tolerance != 0 {
+		return fmt.Sprintf("%s:%s", r.mode, strconv.FormatFloat(r.tolerance, 'g', -1, 64))
+	}
+	return r.mode
+}
+
+func parseNumber(s string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(s), 64)
+}
+
+// compileAnswerRegex compiles an accepted answer so that it must match the whole of a given answer.
+func compileAnswerRegex(answer string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + answer + `)$`)
+}
+
+func collapseWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,605 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// The multiplayer protocol is a stream of JSON encoded messages, one per line, sent over a TCP connection between the
+// host and each player. A player starts by sending a join message and the host replies with a welcome message, or an
+// error message if it can't join. Once enough players have joined, the host sends each question to every player at
+// the same time and the players reply with answer messages. After each question, every player is sent a result message
+// with the leaderboard and once the quiz is over they're sent an end message with the final leaderboard.
+const (
+	msgJoin     = "join"
+	msgWelcome  = "welcome"
+	msgError    = "error"
+	msgQuestion = "question"
+	msgAnswer   = "answer"
+	msgAnswered = "answered"
+	msgResult   = "result"
+	msgEnd      = "end"
+)
+
+// message is a single message of the multiplayer protocol. Which fields are set depends on Type.
+type message struct {
+	Type   string `json:"type"`
+	Name   string `json:"name,omitempty"`
+	Number int    `json:"number,omitempty"`
+	Total  int    `json:"total,omitempty"`
+	Prompt string `json:"prompt,omitempty"`
+	// TimeLimitMS is the time limit for answering a question or 0 if there is no limit.
+	TimeLimitMS int64  `json:"time_limit_ms,omitempty"`
+	Answer      string `json:"answer,omitempty"`
+	Correct     bool   `json:"correct,omitempty"`
+	Expected    string `json:"expected,omitempty"`
+	// Winner is the name of the player who answered the question correctly first when only the first correct answer
+	// is taken.
+	Winner      string             `json:"winner,omitempty"`
+	Leaderboard []leaderboardEntry `json:"leaderboard,omitempty"`
+	Message     string             `json:"message,omitempty"`
+}
+
+type leaderboardEntry struct {
+	Name   string  `json:"name"`
+	Score  int     `json:"score"`
+	Points float64 `json:"points"`
+}
+
+// playerWriteTimeout is how long a message can take to be written to a player before they're disconnected.
+const playerWriteTimeout = 5 * time.Second
+
+// playerOutboxSize is the number of messages which can be waiting to be written to a player before they're disconnected
+// for falling behind.
+const playerOutboxSize = 16
+
+// hostConfig holds the options which a multiplayer quiz is hosted with.
+type hostConfig struct {
+	// players is the number of players to wait for before the quiz starts.
+	players int
+	// firstCorrect is whether each question ends as soon as it is answered correctly, with only the first correct
+	// answer scoring, instead of every player answering it.
+	firstCorrect bool
+}
+
+// hostQuiz hosts the quiz described by config for players joining over TCP on the given port.
+func hostQuiz(config quizConfig, hostConfig hostConfig, port uint, out io.Writer) error {
+	address := fmt.Sprintf(":%d", port)
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("listen on %q: %s", address, err)
+	}
+	defer listener.Close()
+	return runHost(listener, config, hostConfig, out)
+}
+
+// player is a connection to someone taking a multiplayer quiz.
+type player struct {
+	conn net.Conn
+	// outbox holds the messages which are waiting to be written to the player by their own goroutine, so that one slow
+	// player can't hold up sending messages to everyone else.
+	outbox    chan message
+	name      string
+	joined    bool
+	connected bool
+	result    quizResult
+}
+
+// playerMessage is a message received from a player or the error which stopped the next one from being received.
+type playerMessage struct {
+	player *player
+	msg    message
+	err    error
+}
+
+// host runs a multiplayer quiz. All of the quiz's state is owned by the goroutine running it and the messages from each
+// player are passed to it on a single channel, in the order that they're received.
+type host struct {
+	questions  []question
+	config     quizConfig
+	hostConfig hostConfig
+	out        io.Writer
+	players    []*player
+	messages   chan playerMessage
+	done       chan struct{}
+	// writers is the goroutines which are writing messages to players.
+	writers sync.WaitGroup
+
+	mu sync.Mutex
+	// conns is every player who has connected, including those who haven't joined, so that they can all be
+	// disconnected once the quiz is over. closed is whether that has happened.
+	conns  []*player
+	closed bool
+}
+
+// runHost runs the quiz described by config for the players which connect to listener. It returns once the quiz is
+// over, which is once every question has been asked, config.timeout has been reached or every player has left.
+func runHost(listener net.Listener, config quizConfig, hostConfig hostConfig, out io.Writer) error {
+	if hostConfig.players < 1 {
+		return fmt.Errorf("at least 1 player is needed, got %d", hostConfig.players)
+	}
+	questions, err := loadQuestions(config)
+	if err != nil {
+		return err
+	}
+
+	h := &host{
+		questions:  prepareQuestions(questions, config, rand.New(rand.NewSource(config.seed))),
+		config:     config,
+		hostConfig: hostConfig,
+		out:        out,
+		messages:   make(chan playerMessage),
+		done:       make(chan struct{}),
+	}
+	defer h.close()
+	go h.accept(listener)
+
+	fmt.Fprintf(out, "Waiting for %d players to join on %s\n", hostConfig.players, listener.Addr())
+	if err := h.waitForPlayers(); err != nil {
+		return err
+	}
+	reason := h.askQuestions()
+	fmt.Fprintf(out, "%s\n", reason)
+	h.broadcast(message{Type: msgEnd, Message: reason, Leaderboard: h.leaderboard()})
+	return writeLeaderboard(out, h.leaderboard())
+}
+
+// accept accepts connections from listener until the quiz is over, reading each player's messages in its own goroutine.
+func (h *host) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-h.done:
+			default:
+				log.Printf("Failed to accept connection: %s", err)
+			}
+			return
+		}
+		p := &player{conn: conn, outbox: make(chan message, playerOutboxSize), connected: true}
+		h.mu.Lock()
+		if h.closed {
+			h.mu.Unlock()
+			conn.Close()
+			return
+		}
+		h.conns = append(h.conns, p)
+		h.writers.Add(1)
+		h.mu.Unlock()
+		go h.writeMessages(p)
+		go h.readMessages(p)
+	}
+}
+
+func (h *host) readMessages(p *player) {
+	dec := json.NewDecoder(p.conn)
+	for {
+		var msg message
+		err := dec.Decode(&msg)
+		select {
+		case h.messages <- playerMessage{player: p, msg: msg, err: err}:
+		case <-h.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// writeMessages writes the messages sent to p's outbox until it's closed and then closes the connection. The connection
+// is closed straight away if a message can't be written, which the host finds out about when reading from it fails.
+func (h *host) writeMessages(p *player) {
+	defer h.writers.Done()
+	defer p.conn.Close()
+	enc := json.NewEncoder(p.conn)
+	for msg := range p.outbox {
+		p.conn.SetWriteDeadline(time.Now().Add(playerWriteTimeout))
+		if err := enc.Encode(msg); err != nil {
+			return
+		}
+	}
+}
+
+// close disconnects every player, including those who never joined, once the quiz is over and waits for the messages
+// which have already been sent to them to be written.
+func (h *host) close() {
+	close(h.done)
+	h.mu.Lock()
+	h.closed = true
+	conns := h.conns
+	h.mu.Unlock()
+	for _, p := range conns {
+		p.stop()
+	}
+	h.writers.Wait()
+}
+
+// waitForPlayers lets players join until there are enough for the quiz to start.
+func (h *host) waitForPlayers() error {
+	for {
+		pm := <-h.messages
+		p := pm.player
+		if pm.err != nil {
+			h.disconnect(p)
+			continue
+		}
+		if pm.msg.Type != msgJoin || p.joined {
+			continue
+		}
+		name, err := h.playerName(pm.msg.Name)
+		if err != nil {
+			h.send(p, message{Type: msgError, Message: err.Error()})
+			h.disconnect(p)
+			continue
+		}
+		p.name = name
+		p.joined = true
+		p.result = quizResult{hintPenalty: h.config.hintPenalty}
+		h.players = append(h.players, p)
+		h.send(p, message{Type: msgWelcome, Name: name, Total: len(h.questions)})
+		fmt.Fprintf(h.out, "%s joined (%d/%d)\n", name, h.numConnected(), h.hostConfig.players)
+		if h.numConnected() >= h.hostConfig.players {
+			return nil
+		}
+	}
+}
+
+// playerName returns the name which a player asking to join as name should be given, which is made unique by adding a
+// number to it if another player has already taken it.
+func (h *host) playerName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("a name is required to join")
+	}
+	taken := map[string]bool{}
+	for _, p := range h.players {
+		taken[p.name] = true
+	}
+	unique := name
+	for i := 2; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s (%d)", name, i)
+	}
+	return unique, nil
+}
+
+// askQuestions asks each question to every player at the same time and returns why the quiz ended. The time limits
+// are the same as in the terminal: the quiz ends if config.timeout is reached and a question ends if it isn't answered
+// within config.perQuestionTimeout, with the players who haven't answered it scored as wrong. A zero time limit means
+// that there is no limit.
+func (h *host) askQuestions() string {
+	var quizTimeout <-chan time.Time
+	if h.config.timeout > 0 {
+		timer := time.NewTimer(h.config.timeout)
+		defer timer.Stop()
+		quizTimeout = timer.C
+	}
+
+	for i, q := range h.questions {
+		number := i + 1
+		fmt.Fprintf(h.out, "Question %d: %s\n", number, q.prompt())
+		h.broadcast(message{
+			Type:        msgQuestion,
+			Number:      number,
+			Total:       len(h.questions),
+			Prompt:      q.prompt(),
+			TimeLimitMS: h.config.perQuestionTimeout.Milliseconds(),
+		})
+		asked := time.Now()
+
+		var questionTimer *time.Timer
+		var questionTimeout <-chan time.Time
+		if h.config.perQuestionTimeout > 0 {
+			questionTimer = time.NewTimer(h.config.perQuestionTimeout)
+			questionTimeout = questionTimer.C
+		}
+
+		answered := map[*player]bool{}
+		winner := ""
+		for winner == "" && !h.allAnswered(answered) {
+			select {
+			case <-quizTimeout:
+				return fmt.Sprintf("Timed out after %s", h.config.timeout)
+			case <-questionTimeout:
+				fmt.Fprintf(h.out, "Out of time for this question after %s\n", h.config.perQuestionTimeout)
+				for _, p := range h.players {
+					if p.connected && !answered[p] {
+						p.result.addTimeout(q, time.Since(asked))
+						answered[p] = true
+					}
+				}
+			case pm := <-h.messages:
+				p := pm.player
+				if pm.err != nil {
+					h.disconnect(p)
+					continue
+				}
+				switch {
+				case pm.msg.Type == msgJoin && !p.joined:
+					h.send(p, message{Type: msgError, Message: "the quiz has already started"})
+					h.disconnect(p)
+				case pm.msg.Type == msgAnswer && p.joined && pm.msg.Number == number && !answered[p]:
+					p.result.addAnswer(q, pm.msg.Answer, time.Since(asked), false)
+					answered[p] = true
+					h.send(p, message{Type: msgAnswered, Number: number})
+					if h.hostConfig.firstCorrect && q.isCorrect(pm.msg.Answer) {
+						winner = p.name
+					}
+				}
+			}
+		}
+		if questionTimer != nil {
+			questionTimer.Stop()
+		}
+		if winner != "" {
+			// The players who hadn't answered when the question was won are scored as wrong, so that each player's
+			// answers still line up with the questions.
+			for _, p := range h.players {
+				if p.connected && !answered[p] {
+					p.result.addUnanswered(q, time.Since(asked))
+				}
+			}
+		}
+		if h.numConnected() == 0 {
+			return "All players have left"
+		}
+
+		leaderboard := h.leaderboard()
+		for _, p := range h.players {
+			result := message{
+				Type:        msgResult,
+				Number:      number,
+				Expected:    q.expected(),
+				Winner:      winner,
+				Leaderboard: leaderboard,
+			}
+			if answered[p] && len(p.result.answers) > 0 {
+				result.Correct = p.result.answers[len(p.result.answers)-1].correct
+			}
+			h.send(p, result)
+		}
+		if winner != "" {
+			fmt.Fprintf(h.out, "%s answered first\n", winner)
+		}
+		writeLeaderboard(h.out, leaderboard)
+	}
+	return "Quiz finished"
+}
+
+// allAnswered reports whether every player who is still connected has answered the current question.
+func (h *host) allAnswered(answered map[*player]bool) bool {
+	for _, p := range h.players {
+		if p.connected && !answered[p] {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *host) numConnected() int {
+	n := 0
+	for _, p := range h.players {
+		if p.connected {
+			n++
+		}
+	}
+	return n
+}
+
+// leaderboard returns every player who has joined ordered by their weighted score, highest first. Players who have
+// left stay on the leaderboard with the score that they had when they left.
+func (h *host) leaderboard() []leaderboardEntry {
+	var entries []leaderboardEntry
+	for _, p := range h.players {
+		entries = append(entries, leaderboardEntry{Name: p.name, Score: p.result.score, Points: p.result.weightedScore})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Points != entries[j].Points {
+			return entries[i].Points > entries[j].Points
+		}
+		return entries[i].Score > entries[j].Score
+	})
+	return entries
+}
+
+func (h *host) broadcast(msg message) {
+	for _, p := range h.players {
+		h.send(p, msg)
+	}
+}
+
+// send queues msg to be written to p without waiting for it to be written, disconnecting them if they've fallen so far
+// behind that their outbox is full.
+func (h *host) send(p *player, msg message) {
+	if !p.connected {
+		return
+	}
+	select {
+	case p.outbox <- msg:
+	default:
+		h.disconnect(p)
+	}
+}
+
+func (h *host) disconnect(p *player) {
+	if !p.connected {
+		return
+	}
+	p.stop()
+	if p.joined {
+		fmt.Fprintf(h.out, "%s left\n", p.name)
+	}
+}
+
+// stop stops any more messages from being sent to p. Their connection is closed once the messages which have already
+// been sent are written.
+func (p *player) stop() {
+	if !p.connected {
+		return
+	}
+	p.connected = false
+	close(p.outbox)
+}
+
+// writeLeaderboard writes the leaderboard as a table.
+func writeLeaderboard(w io.Writer, leaderboard []leaderboardEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Rank\tPlayer\tCorrect\tPoints")
+	for i, entry := range leaderboard {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\n", i+1, entry.Name, entry.Score, formatPoints(entry.Points))
+	}
+	return tw.Flush()
+}
+
+// joinQuiz joins the multiplayer quiz hosted at address as the player called name, reading answers from in and writing
+// questions and results to out.
+func joinQuiz(address string, name string, in io.Reader, out io.Writer) error {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return fmt.Errorf("connect to %q: %s", address, err)
+	}
+	defer conn.Close()
+
+	console := newConsole(in, out)
+	defer console.Close()
+	return playQuiz(conn, name, console)
+}
+
+// playQuiz takes part in the multiplayer quiz hosted on the other end of conn until it's over. Each line of input from
+// the console answers the question which was asked most recently. Input which is given while there is no question to
+// answer is held until the next question is asked, except that the first line after a question closes without being
+// answered is discarded.
+func playQuiz(conn net.Conn, name string, console *console) error {
+	if err := json.NewEncoder(conn).Encode(message{Type: msgJoin, Name: name}); err != nil {
+		return fmt.Errorf("send join: %s", err)
+	}
+
+	messages := make(chan playerMessage)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		dec := json.NewDecoder(conn)
+		for {
+			var msg message
+			err := dec.Decode(&msg)
+			select {
+			case messages <- playerMessage{msg: msg, err: err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	enc := json.NewEncoder(conn)
+	current := 0
+	inputDone := false
+	// skipped is whether the last question closed before it was answered, in which case the next line of input is
+	// discarded because it may be a late answer to it, as it is in the terminal. The next question is held in
+	// nextQuestion until then so that its answer can't be mistaken for the late one.
+	skipped := false
+	var nextQuestion *message
+	ask := func(msg message) {
+		current = msg.Number
+		if msg.TimeLimitMS > 0 {
+			console.Printf("\nQuestion %d of %d (%s): %s ", msg.Number, msg.Total, time.Duration(msg.TimeLimitMS)*time.Millisecond, msg.Prompt)
+		} else {
+			console.Printf("\nQuestion %d of %d: %s ", msg.Number, msg.Total, msg.Prompt)
+		}
+	}
+	for {
+		// Answers are only read while there's a question to answer so that any given early are kept for it.
+		var answers <-chan answer
+		if (current != 0 || skipped) && !inputDone {
+			answers = console.Answers()
+		}
+
+		select {
+		case a := <-answers:
+			if a.err != nil {
+				if a.err != io.EOF {
+					return fmt.Errorf("input: %s", a.err)
+				}
+				inputDone = true
+				continue
+			}
+			if skipped {
+				skipped = false
+				if nextQuestion != nil {
+					ask(*nextQuestion)
+					nextQuestion = nil
+				}
+				continue
+			}
+			if err := enc.Encode(message{Type: msgAnswer, Number: current, Answer: a.text}); err != nil {
+				return fmt.Errorf("send answer: %s", err)
+			}
+			current = 0
+		case pm := <-messages:
+			if pm.err != nil {
+				if pm.err == io.EOF {
+					return errors.New("host closed the connection before the quiz ended")
+				}
+				return fmt.Errorf("receive message: %s", pm.err)
+			}
+			msg := pm.msg
+			switch msg.Type {
+			case msgWelcome:
+				console.Printf("Joined as %s, waiting for the quiz of %d questions to start\n", msg.Name, msg.Total)
+			case msgError:
+				return fmt.Errorf("host: %s", msg.Message)
+			case msgQuestion:
+				if skipped {
+					nextQuestion = &msg
+				} else {
+					ask(msg)
+				}
+			case msgAnswered:
+				console.Printf("Waiting for the other players\n")
+			case msgResult:
+				unanswered := current == msg.Number
+				if unanswered {
+					current = 0
+					skipped = true
+					console.Printf("\n")
+				}
+				if nextQuestion != nil && nextQuestion.Number == msg.Number {
+					nextQuestion = nil
+				}
+				switch {
+				case msg.Correct:
+					console.Printf("Correct!\n")
+				default:
+					console.Printf("The answer was %s\n", msg.Expected)
+				}
+				if msg.Winner != "" {
+					console.Printf("%s answered first\n", msg.Winner)
+				}
+				if err := writeLeaderboard(console.out, msg.Leaderboard); err != nil {
+					return fmt.Errorf("write leaderboard: %s", err)
+				}
+				if unanswered {
+					console.Printf("Press enter to continue ")
+				}
+			case msgEnd:
+				console.Printf("\n%s\n", msg.Message)
+				if err := writeLeaderboard(console.out, msg.Leaderboard); err != nil {
+					return fmt.Errorf("write leaderboard: %s", err)
+				}
+				return nil
+			}
+		}
+	}
+}
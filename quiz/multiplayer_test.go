@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startHost hosts a quiz of problems on a loopback listener and returns its address and a channel which the host's
+// output is sent on once the quiz is over.
+func startHost(t *testing.T, problems string, config quizConfig, hostConfig hostConfig) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %s", err)
+	}
+	return listener.Addr().String(), startHostOn(t, listener, problems, config, hostConfig)
+}
+
+// startHostOn hosts a quiz of problems on listener and returns a channel which the host's output is sent on once the
+// quiz is over.
+func startHostOn(t *testing.T, listener net.Listener, problems string, config quizConfig, hostConfig hostConfig) <-chan string {
+	t.Helper()
+	config.problemsPath = filepath.Join(t.TempDir(), "problems.csv")
+	mustWriteFile(t, config.problemsPath, problems)
+	t.Cleanup(func() { listener.Close() })
+
+	hostOut := make(chan string, 1)
+	go func() {
+		var out bytes.Buffer
+		if err := runHost(listener, config, hostConfig, &out); err != nil {
+			t.Errorf("runHost returned unexpected err: %s", err)
+		}
+		hostOut <- out.String()
+	}()
+	return hostOut
+}
+
+// pipeListener is a net.Listener whose connections are synchronous in-memory pipes, so that a write to a connection
+// blocks until the other end reads it.
+type pipeListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+// Dial returns the client end of a new connection to the listener.
+func (l *pipeListener) Dial() net.Conn {
+	server, client := net.Pipe()
+	l.conns <- server
+	return client
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
+
+// testPlayer speaks the multiplayer protocol directly so that the order of the players' answers can be controlled.
+type testPlayer struct {
+	t    *testing.T
+	conn net.Conn
+	enc  *json.Encoder
+	dec  *json.Decoder
+}
+
+func mustJoin(t *testing.T, address string, name string) *testPlayer {
+	t.Helper()
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	return mustJoinOn(t, conn, name)
+}
+
+func mustJoinOn(t *testing.T, conn net.Conn, name string) *testPlayer {
+	t.Helper()
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	p := &testPlayer{t: t, conn: conn, enc: json.NewEncoder(conn), dec: json.NewDecoder(conn)}
+	p.send(message{Type: msgJoin, Name: name})
+	p.mustReceive(msgWelcome)
+	return p
+}
+
+func (p *testPlayer) send(msg message) {
+	p.t.Helper()
+	if err := p.enc.Encode(msg); err != nil {
+		p.t.Fatalf("send %s: %s", msg.Type, err)
+	}
+}
+
+func (p *testPlayer) mustReceive(msgType string) message {
+	p.t.Helper()
+	var msg message
+	if err := p.dec.Decode(&msg); err != nil {
+		p.t.Fatalf("receive %s: %s", msgType, err)
+	}
+	if msg.Type != msgType {
+		p.t.Fatalf("received %s message %+v, want %s", msg.Type, msg, msgType)
+	}
+	return msg
+}
+
+func TestHostFirstCorrectAnswer(t *testing.T) {
+	address, hostOut := startHost(t, "5+5,10\n", quizConfig{}, hostConfig{players: 2, firstCorrect: true})
+	alice := mustJoin(t, address, "alice")
+	bob := mustJoin(t, address, "bob")
+
+	alice.mustReceive(msgQuestion)
+	question := bob.mustReceive(msgQuestion)
+	bob.send(message{Type: msgAnswer, Number: question.Number, Answer: "11"})
+	bob.mustReceive(msgAnswered)
+	alice.send(message{Type: msgAnswer, Number: question.Number, Answer: "10"})
+	alice.mustReceive(msgAnswered)
+
+	result := bob.mustReceive(msgResult)
+	if result.Winner != "alice" || result.Correct {
+		t.Errorf("bob was sent result with winner %q and correct %t, want winner alice and correct false", result.Winner, result.Correct)
+	}
+	if result := alice.mustReceive(msgResult); !result.Correct {
+		t.Errorf("alice was sent result with correct false, want true")
+	}
+	end := alice.mustReceive(msgEnd)
+	if len(end.Leaderboard) != 2 || end.Leaderboard[0].Name != "alice" || end.Leaderboard[0].Score != 1 {
+		t.Errorf("final leaderboard = %+v, want alice first with a score of 1", end.Leaderboard)
+	}
+	<-hostOut
+}
+
+func TestHostFirstCorrectScoresUnansweredQuestions(t *testing.T) {
+	questions := []question{newTestQuestion("5+5", "10"), newTestQuestion("1+1", "2")}
+	newPlayer := func(name string) *player {
+		return &player{outbox: make(chan message, playerOutboxSize), name: name, joined: true, connected: true}
+	}
+	alice := newPlayer("alice")
+	bob := newPlayer("bob")
+	h := &host{
+		questions:  questions,
+		hostConfig: hostConfig{players: 2, firstCorrect: true},
+		out:        io.Discard,
+		players:    []*player{alice, bob},
+		messages:   make(chan playerMessage),
+	}
+
+	go func() {
+		h.messages <- playerMessage{player: alice, msg: message{Type: msgAnswer, Number: 1, Answer: "10"}}
+		h.messages <- playerMessage{player: bob, msg: message{Type: msgAnswer, Number: 2, Answer: "2"}}
+	}()
+	if reason := h.askQuestions(); reason != "Quiz finished" {
+		t.Fatalf("askQuestions() = %q, want %q", reason, "Quiz finished")
+	}
+
+	testCases := []struct {
+		player      *player
+		wantCorrect []bool
+	}{
+		{player: alice, wantCorrect: []bool{true, false}},
+		{player: bob, wantCorrect: []bool{false, true}},
+	}
+	for _, tc := range testCases {
+		answers := tc.player.result.answers
+		if len(answers) != len(questions) {
+			t.Errorf("%s has %d answers, want one for each of the %d questions", tc.player.name, len(answers), len(questions))
+			continue
+		}
+		for i, answer := range answers {
+			if answer.question.question != questions[i].question || answer.correct != tc.wantCorrect[i] || answer.timedOut {
+				t.Errorf("%s's answer %d is to %q with correct: %t, timed out: %t, want it to be to %q with correct: %t, timed out: false", tc.player.name, i, answer.question.question, answer.correct, answer.timedOut, questions[i].question, tc.wantCorrect[i])
+			}
+		}
+	}
+}
+
+func TestHostSlowPlayerDoesNotHoldUpOthers(t *testing.T) {
+	listener := newPipeListener()
+	hostOut := startHostOn(t, listener, "5+5,10\n", quizConfig{perQuestionTimeout: 200 * time.Millisecond}, hostConfig{players: 2})
+
+	// The slow player joins but never reads anything, so every write to them blocks.
+	slow := listener.Dial()
+	if err := json.NewEncoder(slow).Encode(message{Type: msgJoin, Name: "slow"}); err != nil {
+		t.Fatalf("send join: %s", err)
+	}
+	alice := mustJoinOn(t, listener.Dial(), "alice")
+	alice.conn.SetDeadline(time.Now().Add(playerWriteTimeout / 2))
+
+	question := alice.mustReceive(msgQuestion)
+	alice.send(message{Type: msgAnswer, Number: question.Number, Answer: "10"})
+	alice.mustReceive(msgAnswered)
+	alice.mustReceive(msgResult)
+	alice.mustReceive(msgEnd)
+
+	slow.Close()
+	<-hostOut
+}
+
+func TestHostClosesConnectionsWhichNeverJoin(t *testing.T) {
+	address, hostOut := startHost(t, "5+5,10\n", quizConfig{}, hostConfig{players: 1})
+	lurker, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("dial: %s", err)
+	}
+	defer lurker.Close()
+	alice := mustJoin(t, address, "alice")
+
+	alice.mustReceive(msgQuestion)
+	alice.send(message{Type: msgAnswer, Number: 1, Answer: "10"})
+	alice.mustReceive(msgAnswered)
+	alice.mustReceive(msgResult)
+	alice.mustReceive(msgEnd)
+	<-hostOut
+
+	lurker.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := lurker.Read(make([]byte, 1)); errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("connection which never joined is still open after the quiz ended")
+	}
+}
+
+func TestHostPerQuestionTimeout(t *testing.T) {
+	address, hostOut := startHost(t, "5+5,10\n7+3,10\n", quizConfig{perQuestionTimeout: 50 * time.Millisecond}, hostConfig{players: 1})
+	alice := mustJoin(t, address, "alice")
+
+	question := alice.mustReceive(msgQuestion)
+	if question.TimeLimitMS != 50 {
+		t.Errorf("question was sent with time limit %dms, want 50ms", question.TimeLimitMS)
+	}
+	alice.mustReceive(msgResult)
+	alice.mustReceive(msgQuestion)
+	alice.send(message{Type: msgAnswer, Number: 2, Answer: "10"})
+	alice.mustReceive(msgAnswered)
+	alice.mustReceive(msgResult)
+	end := alice.mustReceive(msgEnd)
+	if end.Leaderboard[0].Score != 1 {
+		t.Errorf("final score = %d, want 1", end.Leaderboard[0].Score)
+	}
+	if out := <-hostOut; !strings.Contains(out, "Out of time for this question after 50ms") {
+		t.Errorf("host wrote %q, want it to contain the question timing out", out)
+	}
+}
+
+func TestHostQuizTimeout(t *testing.T) {
+	address, hostOut := startHost(t, "5+5,10\n", quizConfig{timeout: 50 * time.Millisecond}, hostConfig{players: 1})
+	alice := mustJoin(t, address, "alice")
+
+	alice.mustReceive(msgQuestion)
+	end := alice.mustReceive(msgEnd)
+	if want := "Timed out after 50ms"; end.Message != want {
+		t.Errorf("end message = %q, want %q", end.Message, want)
+	}
+	<-hostOut
+}
+
+func TestJoinQuiz(t *testing.T) {
+	address, hostOut := startHost(t, "5+5,10\n7+4,11\n", quizConfig{}, hostConfig{players: 2})
+
+	type playerOutput struct {
+		out string
+		err error
+	}
+	play := func(name string, answers string) <-chan playerOutput {
+		done := make(chan playerOutput, 1)
+		go func() {
+			var out bytes.Buffer
+			err := joinQuiz(address, name, strings.NewReader(answers), &out)
+			done <- playerOutput{out: out.String(), err: err}
+		}()
+		return done
+	}
+	alice := play("alice", "10\n10\n")
+	bob := play("bob", "10\n11\n")
+
+	for name, done := range map[string]<-chan playerOutput{"alice": alice, "bob": bob} {
+		output := <-done
+		if output.err != nil {
+			t.Errorf("joinQuiz as %s returned unexpected err: %s", name, output.err)
+		}
+		if !strings.Contains(output.out, "Quiz finished") {
+			t.Errorf("joinQuiz as %s wrote %q, want it to contain Quiz finished", name, output.out)
+		}
+	}
+
+	out := <-hostOut
+	final := out[strings.LastIndex(out, "Quiz finished"):]
+	if strings.Index(final, "bob") > strings.Index(final, "alice") {
+		t.Errorf("host wrote final leaderboard %q, want bob ranked above alice", final)
+	}
+}
+
+func TestPlayQuizDiscardsLateAnswer(t *testing.T) {
+	hostConn, playerConn := net.Pipe()
+	defer hostConn.Close()
+	in, inWriter := io.Pipe()
+	defer inWriter.Close()
+	console := newConsole(in, io.Discard)
+	defer console.Close()
+
+	played := make(chan error, 1)
+	go func() {
+		played <- playQuiz(playerConn, "alice", console)
+	}()
+
+	host := &testPlayer{t: t, conn: hostConn, enc: json.NewEncoder(hostConn), dec: json.NewDecoder(hostConn)}
+	hostConn.SetDeadline(time.Now().Add(5 * time.Second))
+	host.mustReceive(msgJoin)
+	host.send(message{Type: msgWelcome, Name: "alice", Total: 2})
+	host.send(message{Type: msgQuestion, Number: 1, Total: 2, Prompt: "5+5"})
+	// The first question closes before it's answered, so the answer which arrives afterwards is late.
+	host.send(message{Type: msgResult, Number: 1, Expected: "10"})
+	host.send(message{Type: msgQuestion, Number: 2, Total: 2, Prompt: "1+1"})
+	io.WriteString(inWriter, "10\n2\n")
+
+	answer := host.mustReceive(msgAnswer)
+	if answer.Number != 2 || answer.Answer != "2" {
+		t.Errorf("player answered question %d with %q, want question 2 answered with %q", answer.Number, answer.Answer, "2")
+	}
+	host.send(message{Type: msgEnd, Message: "Quiz finished"})
+	if err := <-played; err != nil {
+		t.Errorf("playQuiz returned unexpected err: %s", err)
+	}
+}
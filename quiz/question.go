@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// The types of question which can be asked.
+const (
+	// textQuestion is answered by typing the answer, which is checked using the question's match rule.
+	textQuestion = "text"
+	// choiceQuestion is answered by choosing one or more of the question's lettered options.
+	choiceQuestion = "choice"
+	// trueFalseQuestion is answered with true or false.
+	trueFalseQuestion = "truefalse"
+)
+
+type question struct {
+	kind     string
+	question string
+	// answers are the answers which are accepted as correct according to match. For true/false questions, this is
+	// either "true" or "false".
+	answers []string
+	match   matchRule
+	// options are the options which can be chosen from in a multiple choice question.
+	options  []option
+	category string
+	tags     []string
+	// weight is how much the question is worth in the weighted score.
+	weight float64
+	hint   string
+	// template describes the questions that this question expands into when it's loaded, if it's not nil. The question
+	// and hint are then templates and answers is empty.
+	template *questionTemplate
+	// index is the position of the question in the bank that it was loaded from, which identifies it when a suspended
+	// quiz is resumed since the texts of questions aren't unique.
+	index int
+}
+
+// hasAnyTag reports whether the question has any of tags, ignoring case.
+func (q question) hasAnyTag(tags []string) bool {
+	for _, want := range tags {
+		for _, tag := range q.tags {
+			if strings.EqualFold(tag, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type option struct {
+	text    string
+	correct bool
+}
+
+// prompt returns the text which the question is asked with.
+func (q question) prompt() string {
+	switch q.kind {
+	case choiceQuestion:
+		var b strings.Builder
+		b.WriteString(q.question)
+		for i, option := range q.options {
+			fmt.Fprintf(&b, "\n  %s) %s", optionLetter(i), option.text)
+		}
+		letters := fmt.Sprintf("%s-%s", optionLetter(0), optionLetter(len(q.options)-1))
+		if q.numCorrectOptions() > 1 {
+			fmt.Fprintf(&b, "\nChoose all that apply from %s, separated by commas:", letters)
+		} else {
+			fmt.Fprintf(&b, "\nChoose one of %s:", letters)
+		}
+		return b.String()
+	case trueFalseQuestion:
+		return q.question + " (true/false)"
+	default:
+		return q.question
+	}
+}
+
+// isCorrect reports whether answer is correct. For a multiple choice question, this means that the letters of all the
+// correct options and no others have been given.
+func (q question) isCorrect(answer string) bool {
+	switch q.kind {
+	case choiceQuestion:
+		chosen, ok := parseChoices(answer, len(q.options))
+		if !ok {
+			return false
+		}
+		for i, option := range q.options {
+			if chosen[i] != option.correct {
+				return false
+			}
+		}
+		return true
+	case trueFalseQuestion:
+		given, ok := parseTrueFalse(answer)
+		return ok && strconv.FormatBool(given) == q.answers[0]
+	default:
+		for _, accepted := range q.answers {
+			if q.match.matches(answer, accepted) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// credit returns the fraction of the question which answer gets right. Multiple choice questions with several correct
+// options get partial credit for each correct option chosen, less any incorrect options chosen. Other questions are
+// either right or wrong.
+func (q question) credit(answer string) float64 {
+	numCorrect := q.numCorrectOptions()
+	if q.kind != choiceQuestion || numCorrect < 2 {
+		if q.isCorrect(answer) {
+			return 1
+		}
+		return 0
+	}
+
+	chosen, ok := parseChoices(answer, len(q.options))
+	if !ok {
+		return 0
+	}
+	net := 0
+	for i := range chosen {
+		if q.options[i].correct {
+			net++
+		} else {
+			net--
+		}
+	}
+	if net <= 0 {
+		return 0
+	}
+	return float64(net) / float64(numCorrect)
+}
+
+// expected returns a description of the correct answer to the question.
+func (q question) expected() string {
+	switch q.kind {
+	case choiceQuestion:
+		var letters []string
+		for i, option := range q.options {
+			if option.correct {
+				letters = append(letters, optionLetter(i))
+			}
+		}
+		return strings.Join(letters, ",")
+	default:
+		return strings.Join(q.answers, " or ")
+	}
+}
+
+// shuffleOptions returns a copy of the question with the options of a multiple choice question in a random order.
+func (q question) shuffleOptions(rng *rand.Rand) question {
+	if q.kind != choiceQuestion {
+		return q
+	}
+	options := make([]option, len(q.options))
+	copy(options, q.options)
+	rng.Shuffle(len(options), func(i, j int) {
+		options[i], options[j] = options[j], options[i]
+	})
+	q.options = options
+	return q
+}
+
+func (q question) numCorrectOptions() int {
+	n := 0
+	for _, option := range q.options {
+		if option.correct {
+			n++
+		}
+	}
+	return n
+}
+
+// optionLetter returns the letter which the option at index i is chosen with.
+func optionLetter(i int) string {
+	return string(rune('a' + i))
+}
+
+// optionIndex returns the index of the option chosen by letter or false if letter doesn't refer to one of numOptions
+// options.
+func optionIndex(letter string, numOptions int) (int, bool) {
+	letter = strings.ToLower(strings.TrimSpace(letter))
+	if len(letter) != 1 {
+		return 0, false
+	}
+	i := int(letter[0] - 'a')
+	if i < 0 || i >= numOptions {
+		return 0, false
+	}
+	return i, true
+}
+
+// parseChoices parses a comma or space separated list of option letters into the set of chosen option indexes.
+func parseChoices(answer string, numOptions int) (map[int]bool, bool) {
+	letters := strings.FieldsFunc(answer, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	if len(letters) == 0 {
+		return nil, false
+	}
+	chosen := map[int]bool{}
+	for _, letter := range letters {
+		i, ok := optionIndex(letter, numOptions)
+		if !ok {
+			return nil, false
+		}
+		chosen[i] = true
+	}
+	return chosen, true
+}
+
+func parseTrueFalse(answer string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "true", "t", "yes", "y":
+		return true, true
+	case "false", "f", "no", "n":
+		return false, true
+	default:
+		return false, false
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestIsCorrect(t *testing.T) {
+	testCases := []struct {
+		name     string
+		question question
+		answer   string
+		want     bool
+	}{
+		{
+			name:     "exact matches identical answer",
+			question: question{answers: []string{"Paris"}, match: matchRule{mode: matchExact}},
+			answer:   "Paris",
+			want:     true,
+		},
+		{
+			name:     "exact does not match different case",
+			question: question{answers: []string{"Paris"}, match: matchRule{mode: matchExact}},
+			answer:   "paris",
+			want:     false,
+		},
+		{
+			name:     "nocase matches different case",
+			question: question{answers: []string{"Paris"}, match: matchRule{mode: matchCaseInsensitive}},
+			answer:   "PARIS",
+			want:     true,
+		},
+		{
+			name:     "whitespace matches collapsed whitespace",
+			question: question{answers: []string{"new york"}, match: matchRule{mode: matchWhitespace}},
+			answer:   "new    york",
+			want:     true,
+		},
+		{
+			name:     "numeric matches equivalent number",
+			question: question{answers: []string{"10"}, match: matchRule{mode: matchNumeric}},
+			answer:   "10.0",
+			want:     true,
+		},
+		{
+			name:     "numeric matches within tolerance",
+			question: question{answers: []string{"3.14159"}, match: matchRule{mode: matchNumeric, tolerance: 0.01}},
+			answer:   "3.14",
+			want:     true,
+		},
+		{
+			name:     "numeric does not match outside tolerance",
+			question: question{answers: []string{"3.14159"}, match: matchRule{mode: matchNumeric, tolerance: 0.001}},
+			answer:   "3.14",
+			want:     false,
+		},
+		{
+			name:     "matches any accepted answer",
+			question: question{answers: []string{"colour", "color"}, match: matchRule{mode: matchExact}},
+			answer:   "color",
+			want:     true,
+		},
+		{
+			name:     "regex must match whole answer",
+			question: question{answers: []string{"colou?r"}, match: matchRule{mode: matchRegex}},
+			answer:   "colors",
+			want:     false,
+		},
+		{
+			name:     "choice matches all correct letters in any order",
+			question: question{kind: choiceQuestion, options: []option{{"2", true}, {"4", false}, {"5", true}}},
+			answer:   "C, a",
+			want:     true,
+		},
+		{
+			name:     "choice does not match subset of correct letters",
+			question: question{kind: choiceQuestion, options: []option{{"2", true}, {"4", false}, {"5", true}}},
+			answer:   "a",
+			want:     false,
+		},
+		{
+			name:     "choice does not match letter outside options",
+			question: question{kind: choiceQuestion, options: []option{{"2", true}, {"4", false}}},
+			answer:   "z",
+			want:     false,
+		},
+		{
+			name:     "true/false matches abbreviation",
+			question: question{kind: trueFalseQuestion, answers: []string{"false"}},
+			answer:   "N",
+			want:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.question.isCorrect(tc.answer); got != tc.want {
+				t.Errorf("isCorrect(%q) on %+v = %t, want %t", tc.answer, tc.question, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCredit(t *testing.T) {
+	primes := question{kind: choiceQuestion, options: []option{{"2", true}, {"4", false}, {"5", true}, {"7", true}}}
+
+	testCases := []struct {
+		name     string
+		question question
+		answer   string
+		want     float64
+	}{
+		{
+			name:     "all correct options get full credit",
+			question: primes,
+			answer:   "a,c,d",
+			want:     1,
+		},
+		{
+			name:     "some correct options get partial credit",
+			question: primes,
+			answer:   "a,c",
+			want:     2.0 / 3,
+		},
+		{
+			name:     "incorrect options cancel out correct ones",
+			question: primes,
+			answer:   "a,b",
+			want:     0,
+		},
+		{
+			name:     "single answer question is all or nothing",
+			question: newTestQuestion("1+1", "2"),
+			answer:   "2",
+			want:     1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.question.credit(tc.answer); got != tc.want {
+				t.Errorf("credit(%q) on %+v = %g, want %g", tc.answer, tc.question, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHasAnyTag(t *testing.T) {
+	testCases := []struct {
+		name     string
+		question question
+		tags     []string
+		want     bool
+	}{
+		{
+			name:     "matches one of the tags",
+			question: question{tags: []string{"europe", "capitals"}},
+			tags:     []string{"asia", "capitals"},
+			want:     true,
+		},
+		{
+			name:     "matches ignoring case",
+			question: question{tags: []string{"Europe"}},
+			tags:     []string{"EUROPE"},
+			want:     true,
+		},
+		{
+			name:     "does not match other tags",
+			question: question{tags: []string{"europe"}},
+			tags:     []string{"asia"},
+			want:     false,
+		},
+		{
+			name:     "question without tags does not match",
+			question: question{},
+			tags:     []string{"europe"},
+			want:     false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.question.hasAnyTag(tc.tags); got != tc.want {
+				t.Errorf("hasAnyTag(%q) on %+v = %t, want %t", tc.tags, tc.question, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestShuffleOptions(t *testing.T) {
+	primes := question{kind: choiceQuestion, options: []option{{"2", true}, {"4", false}, {"5", true}, {"6", false}, {"7", true}, {"9", false}}}
+
+	testCases := []struct {
+		name     string
+		question question
+	}{
+		{
+			name:     "multiple choice question",
+			question: primes,
+		},
+		{
+			name:     "text question",
+			question: newTestQuestion("1+1", "2"),
+		},
+		{
+			name:     "true/false question",
+			question: question{kind: trueFalseQuestion, question: "Go is compiled", answers: []string{"true"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			original := tc.question.options
+			originalCopy := append([]option(nil), original...)
+
+			got := tc.question.shuffleOptions(rand.New(rand.NewSource(1)))
+
+			if !reflect.DeepEqual(original, originalCopy) {
+				t.Errorf("shuffleOptions modified the options of the original question to %v, want %v", original, originalCopy)
+			}
+			gotSorted := append([]option(nil), got.options...)
+			sort.Slice(gotSorted, func(i, j int) bool { return gotSorted[i].text < gotSorted[j].text })
+			if !reflect.DeepEqual(gotSorted, originalCopy) {
+				t.Errorf("shuffleOptions returned options %v, want a permutation of %v", got.options, originalCopy)
+			}
+			got.options = tc.question.options
+			if !reflect.DeepEqual(got, tc.question) {
+				t.Errorf("shuffleOptions changed more than the options of %+v, got %+v", tc.question, got)
+			}
+		})
+	}
+
+	// The order of the options should actually change for at least one seed.
+	moved := false
+	for seed := int64(0); seed < 10 && !moved; seed++ {
+		moved = !reflect.DeepEqual(primes.shuffleOptions(rand.New(rand.NewSource(seed))).options, primes.options)
+	}
+	if !moved {
+		t.Errorf("shuffleOptions didn't change the order of the options of %+v with any of 10 seeds", primes)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunQuizWithScriptedAnswers(t *testing.T) {
+	testCases := []struct {
+		name      string
+		problems  string
+		answers   string
+		wantScore string
+	}{
+		{
+			name:      "scores all answers",
+			problems:  "5+5,10\n7+3,10\n1+1,2\n",
+			answers:   "\n10\n10\n3\n",
+			wantScore: "Score: 2 / 3",
+		},
+		{
+			name:      "ends quiz when answers run out",
+			problems:  "5+5,10\n7+3,10\n1+1,2\n",
+			answers:   "\n10\n",
+			wantScore: "Score: 1 / 3",
+		},
+		{
+			name:      "accepts final answer without trailing newline",
+			problems:  "5+5,10\n",
+			answers:   "\n10",
+			wantScore: "Score: 1 / 1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			problemsPath := filepath.Join(t.TempDir(), "problems.csv")
+			mustWriteFile(t, problemsPath, tc.problems)
+
+			var out bytes.Buffer
+			if _, err := runQuiz(quizConfig{problemsPath: problemsPath, output: "text"}, strings.NewReader(tc.answers), &out, &out); err != nil {
+				t.Fatalf("runQuiz returned unexpected err: %s", err)
+			}
+
+			if !strings.Contains(out.String(), tc.wantScore) {
+				t.Errorf("runQuiz with answers %q wrote %q, want it to contain %q", tc.answers, out.String(), tc.wantScore)
+			}
+		})
+	}
+}
+
+func TestRunQuizSaveAndResume(t *testing.T) {
+	dir := t.TempDir()
+	problemsPath := filepath.Join(dir, "problems.csv")
+	mustWriteFile(t, problemsPath, "5+5,10\n7+3,10\n1+1,2\n")
+	statePath := filepath.Join(dir, "state.json")
+
+	config := quizConfig{problemsPath: problemsPath, output: "text", timeout: time.Minute, statePath: statePath}
+	var out bytes.Buffer
+	if _, err := runQuiz(config, strings.NewReader("\n10\n:save\n"), &out, &out); err != nil {
+		t.Fatalf("runQuiz returned unexpected err: %s", err)
+	}
+	if strings.Contains(out.String(), "Score:") {
+		t.Fatalf("runQuiz with :save wrote %q, want no score", out.String())
+	}
+
+	saved, err := readSavedQuiz(statePath)
+	if err != nil {
+		t.Fatalf("readSavedQuiz returned unexpected err: %s", err)
+	}
+	if len(saved.Answers) != 1 || saved.TimeLeftMS <= 0 || saved.TimeLeftMS > time.Minute.Milliseconds() {
+		t.Errorf("saved quiz has %d answers and %dms left, want 1 answer and between 0ms and 60000ms left", len(saved.Answers), saved.TimeLeftMS)
+	}
+
+	config = quizConfig{output: "text", resumePath: statePath, statePath: statePath}
+	out.Reset()
+	if _, err := runQuiz(config, strings.NewReader("\n10\n3\n"), &out, &out); err != nil {
+		t.Fatalf("runQuiz returned unexpected err: %s", err)
+	}
+	if wantScore := "Score: 2 / 3"; !strings.Contains(out.String(), wantScore) {
+		t.Errorf("resumed runQuiz wrote %q, want it to contain %q", out.String(), wantScore)
+	}
+	if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+		t.Errorf("state file still exists after the resumed quiz was finished")
+	}
+}
+
+func TestRunQuizResumesQuestionsWithTheSameText(t *testing.T) {
+	dir := t.TempDir()
+	problemsPath := filepath.Join(dir, "problems.csv")
+	mustWriteFile(t, problemsPath, "Next number?,1\nNext number?,2\n")
+	statePath := filepath.Join(dir, "state.json")
+
+	config := quizConfig{problemsPath: problemsPath, output: "text", statePath: statePath}
+	var out bytes.Buffer
+	if _, err := runQuiz(config, strings.NewReader("\n1\n:save\n"), &out, &out); err != nil {
+		t.Fatalf("runQuiz returned unexpected err: %s", err)
+	}
+
+	config = quizConfig{output: "text", resumePath: statePath, statePath: statePath}
+	out.Reset()
+	if _, err := runQuiz(config, strings.NewReader("\n2\n"), &out, &out); err != nil {
+		t.Fatalf("runQuiz returned unexpected err: %s", err)
+	}
+	if wantScore := "Score: 2 / 2"; !strings.Contains(out.String(), wantScore) {
+		t.Errorf("resumed runQuiz wrote %q, want it to contain %q", out.String(), wantScore)
+	}
+}
+
+func TestRunQuizRejectsResumingChangedProblemsFile(t *testing.T) {
+	dir := t.TempDir()
+	problemsPath := filepath.Join(dir, "problems.csv")
+	mustWriteFile(t, problemsPath, "5+5,10\n1+1,2\n")
+	statePath := filepath.Join(dir, "state.json")
+
+	config := quizConfig{problemsPath: problemsPath, output: "text", statePath: statePath}
+	var out bytes.Buffer
+	if _, err := runQuiz(config, strings.NewReader("\n10\n:save\n"), &out, &out); err != nil {
+		t.Fatalf("runQuiz returned unexpected err: %s", err)
+	}
+
+	mustWriteFile(t, problemsPath, "1+1,2\n5+5,10\n")
+	config = quizConfig{output: "text", resumePath: statePath, statePath: statePath}
+	out.Reset()
+	_, err := runQuiz(config, strings.NewReader("\n2\n"), &out, &out)
+	if wantErr := "is no longer in the problems file"; err == nil || !strings.Contains(err.Error(), wantErr) {
+		t.Errorf("resumed runQuiz with a reordered problems file returned err: %v, want err containing %q", err, wantErr)
+	}
+}
+
+func TestRunQuizPerQuestionTimeout(t *testing.T) {
+	problemsPath := filepath.Join(t.TempDir(), "problems.csv")
+	mustWriteFile(t, problemsPath, "1+1,2\n2+2,4\n3+3,6\n")
+
+	in, inWriter := io.Pipe()
+	go func() {
+		defer inWriter.Close()
+		io.WriteString(inWriter, "\n")
+		// The first question times out before its answer arrives, so the answer should be discarded rather than taken
+		// as the answer to the second question.
+		time.Sleep(500 * time.Millisecond)
+		io.WriteString(inWriter, "2\n4\n6\n")
+	}()
+
+	var out bytes.Buffer
+	config := quizConfig{problemsPath: problemsPath, output: "text", perQuestionTimeout: 200 * time.Millisecond}
+	if _, err := runQuiz(config, in, &out, &out); err != nil {
+		t.Fatalf("runQuiz returned unexpected err: %s", err)
+	}
+
+	for _, want := range []string{"Out of time for this question after 200ms", "Score: 2 / 3", "Timed out on:\n  1+1\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("runQuiz with a question which timed out wrote %q, want it to contain %q", out.String(), want)
+		}
+	}
+}
+
+func TestFilterQuestions(t *testing.T) {
+	paris := question{question: "Capital of France?", category: "Geography", tags: []string{"europe", "capitals"}}
+	tokyo := question{question: "Capital of Japan?", category: "geography", tags: []string{"asia", "capitals"}}
+	everest := question{question: "Highest mountain?", category: "geography", tags: []string{"asia"}}
+	atoms := question{question: "Smallest particle?", category: "science"}
+	questions := []question{paris, tokyo, everest, atoms}
+
+	testCases := []struct {
+		name    string
+		config  quizConfig
+		want    []question
+		wantErr bool
+	}{
+		{
+			name:   "no filters returns all questions",
+			config: quizConfig{},
+			want:   questions,
+		},
+		{
+			name:   "category ignores case",
+			config: quizConfig{category: "GEOGRAPHY"},
+			want:   []question{paris, tokyo, everest},
+		},
+		{
+			name:   "tags match questions with any of them",
+			config: quizConfig{tags: []string{"europe", "asia"}},
+			want:   []question{paris, tokyo, everest},
+		},
+		{
+			name:   "category and tags must both match",
+			config: quizConfig{category: "geography", tags: []string{"capitals"}},
+			want:   []question{paris, tokyo},
+		},
+		{
+			name:    "no matching questions is an error",
+			config:  quizConfig{category: "science", tags: []string{"capitals"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := filterQuestions(questions, tc.config)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("filterQuestions with category %q and tags %q returned no err, want err", tc.config.category, tc.config.tags)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("filterQuestions with category %q and tags %q returned unexpected err: %s", tc.config.category, tc.config.tags, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("filterQuestions with category %q and tags %q = %v, want %v", tc.config.category, tc.config.tags, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// reportWriter writes the results of a quiz in a particular format.
+type reportWriter func(w io.Writer, report quizReport) error
+
+var formatToReportWriter = map[string]reportWriter{
+	"text":  writeTextReport,
+	"json":  writeJSONReport,
+	"junit": writeJUnitReport,
+}
+
+// quizReport is the results of a single run of a quiz as they are written in the machine-readable report formats.
+type quizReport struct {
+	Bank  string `json:"bank"`
+	Score int    `json:"score"`
+	Total int    `json:"total"`
+	// WeightedScore is the sum of the points scored for each question out of MaxWeightedScore, the sum of their
+	// weights. Percentage is the weighted score as a percentage.
+	WeightedScore    float64          `json:"weighted_score"`
+	MaxWeightedScore float64          `json:"max_weighted_score"`
+	Percentage       float64          `json:"percentage"`
+	PassMark         float64          `json:"pass_mark"`
+	Passed           bool             `json:"passed"`
+	OutOfTime        bool             `json:"out_of_time"`
+	DurationMS       int64            `json:"duration_ms"`
+	Categories       []categoryReport `json:"categories,omitempty"`
+	Questions        []questionReport `json:"questions"`
+}
+
+// categoryReport is the score for the questions in a single category.
+type categoryReport struct {
+	Category string `json:"category"`
+	Score    int    `json:"score"`
+	Total    int    `json:"total"`
+}
+
+type questionReport struct {
+	Question   string   `json:"question"`
+	Category   string   `json:"category,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Expected   string   `json:"expected"`
+	Answer     string   `json:"answer"`
+	Answered   bool     `json:"answered"`
+	Correct    bool     `json:"correct"`
+	Weight     float64  `json:"weight"`
+	Credit     float64  `json:"credit"`
+	HintUsed   bool     `json:"hint_used"`
+	Points     float64  `json:"points"`
+	TimedOut   bool     `json:"timed_out"`
+	DurationMS int64    `json:"duration_ms"`
+}
+
+// newQuizReport returns the report of a run of the quiz described by config which asked questions. Questions which
+// weren't reached before the quiz ended are reported as unanswered.
+func newQuizReport(config quizConfig, questions []question, result quizResult, duration time.Duration) quizReport {
+	report := quizReport{
+		Bank:          config.bankName(),
+		Score:         result.score,
+		Total:         len(questions),
+		WeightedScore: result.weightedScore,
+		PassMark:      config.passMark,
+		OutOfTime:     result.outOfTime,
+		DurationMS:    duration.Milliseconds(),
+	}
+
+	for i, q := range questions {
+		questionReport := questionReport{
+			Question: q.question,
+			Category: q.category,
+			Tags:     q.tags,
+			Expected: q.expected(),
+			Weight:   q.weight,
+		}
+		report.MaxWeightedScore += q.weight
+		if i < len(result.answers) {
+			given := result.answers[i]
+			questionReport.Answer = given.answer
+			questionReport.Answered = given.answered
+			questionReport.Correct = given.correct
+			questionReport.Credit = given.credit
+			questionReport.HintUsed = given.hinted
+			questionReport.Points = given.points
+			questionReport.TimedOut = given.timedOut
+			questionReport.DurationMS = given.elapsed.Milliseconds()
+		}
+		report.Questions = append(report.Questions, questionReport)
+	}
+	report.Categories = categoryReports(report.Questions)
+
+	if report.MaxWeightedScore > 0 {
+		report.Percentage = 100 * report.WeightedScore / report.MaxWeightedScore
+	}
+	report.Passed = report.Percentage >= report.PassMark
+	return report
+}
+
+// uncategorised is the category that questions without one are reported under.
+const uncategorised = "uncategorised"
+
+// categoryReports returns the score for each category in the order that they're first asked, or nil if none of the
+// questions have a category.
+func categoryReports(questions []questionReport) []categoryReport {
+	var reports []categoryReport
+	categoryToIndex := map[string]int{}
+	hasCategory := false
+	for _, question := range questions {
+		category := question.Category
+		if category == "" {
+			category = uncategorised
+		} else {
+			hasCategory = true
+		}
+		i, ok := categoryToIndex[category]
+		if !ok {
+			i = len(reports)
+			categoryToIndex[category] = i
+			reports = append(reports, categoryReport{Category: category})
+		}
+		reports[i].Total++
+		if question.Correct {
+			reports[i].Score++
+		}
+	}
+	if !hasCategory {
+		return nil
+	}
+	return reports
+}
+
+func writeTextReport(w io.Writer, report quizReport) error {
+	fmt.Fprintf(w, "Score: %d / %d\n", report.Score, report.Total)
+	fmt.Fprintf(w, "Weighted score: %s / %s\n", formatPoints(report.WeightedScore), formatPoints(report.MaxWeightedScore))
+	var timedOut []string
+	for _, question := range report.Questions {
+		if question.TimedOut {
+			timedOut = append(timedOut, question.Question)
+		}
+	}
+	if len(timedOut) > 0 {
+		fmt.Fprintln(w, "Timed out on:")
+		for _, question := range timedOut {
+			fmt.Fprintf(w, "  %s\n", question)
+		}
+	}
+	if len(report.Categories) > 0 {
+		fmt.Fprintln(w, "By category:")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, category := range report.Categories {
+			fmt.Fprintf(tw, "  %s\t%d / %d\n", category.Category, category.Score, category.Total)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if report.PassMark > 0 {
+		if report.Passed {
+			fmt.Fprintf(w, "Passed with %.0f%%, pass mark is %.0f%%\n", report.Percentage, report.PassMark)
+		} else {
+			fmt.Fprintf(w, "Failed with %.0f%%, pass mark is %.0f%%\n", report.Percentage, report.PassMark)
+		}
+	}
+	return nil
+}
+
+// formatPoints formats a weighted score to at most 2 decimal places.
+func formatPoints(points float64) string {
+	return strconv.FormatFloat(math.Round(points*100)/100, 'f', -1, 64)
+}
+
+func writeJSONReport(w io.Writer, report quizReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+}
+
+// writeJUnitReport writes the report as JUnit XML with a test suite for the quiz and a test case for each question.
+func writeJUnitReport(w io.Writer, report quizReport) error {
+	suite := junitTestSuite{
+		Name:  report.Bank,
+		Tests: report.Total,
+		Time:  junitSeconds(report.DurationMS),
+	}
+	for _, question := range report.Questions {
+		className := report.Bank
+		if question.Category != "" {
+			className = question.Category
+		}
+		testCase := junitTestCase{
+			Name:      question.Question,
+			ClassName: className,
+			Time:      junitSeconds(question.DurationMS),
+		}
+		switch {
+		case question.TimedOut:
+			testCase.Failure = &junitFailure{Type: "timeout", Message: "timed out"}
+		case !question.Answered:
+			testCase.Failure = &junitFailure{Type: "unanswered", Message: "not answered"}
+		case !question.Correct:
+			testCase.Failure = &junitFailure{Type: "incorrect", Message: fmt.Sprintf("answered %q, expected %q", question.Answer, question.Expected)}
+		}
+		if testCase.Failure != nil {
+			suite.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitSeconds(ms int64) string {
+	return fmt.Sprintf("%.3f", float64(ms)/1000)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden files in test_data")
+
+func TestCategoryReports(t *testing.T) {
+	testCases := []struct {
+		name      string
+		questions []questionReport
+		want      []categoryReport
+	}{
+		{
+			name: "scores each category in the order they're first asked",
+			questions: []questionReport{
+				{Category: "science", Correct: true},
+				{Category: "geography", Correct: false},
+				{Category: "science", Correct: false},
+				{Category: "geography", Correct: true},
+				{Category: "science", Correct: true},
+			},
+			want: []categoryReport{
+				{Category: "science", Score: 2, Total: 3},
+				{Category: "geography", Score: 1, Total: 2},
+			},
+		},
+		{
+			name: "questions without a category are uncategorised",
+			questions: []questionReport{
+				{Correct: true},
+				{Category: "science", Correct: true},
+				{Correct: false},
+			},
+			want: []categoryReport{
+				{Category: uncategorised, Score: 1, Total: 2},
+				{Category: "science", Score: 1, Total: 1},
+			},
+		},
+		{
+			name: "no categories returns nil",
+			questions: []questionReport{
+				{Correct: true},
+				{Correct: false},
+			},
+			want: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := categoryReports(tc.questions); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("categoryReports(%+v) = %+v, want %+v", tc.questions, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewQuizReportTimedOutQuestions(t *testing.T) {
+	questions := []question{newTestQuestion("1+1", "2"), newTestQuestion("2+2", "4"), newTestQuestion("3+3", "6")}
+	var result quizResult
+	result.addTimeout(questions[0], 3*time.Second)
+	result.addAnswer(questions[1], "4", time.Second, false)
+
+	report := newQuizReport(quizConfig{}, questions, result, 4*time.Second)
+
+	want := []questionReport{
+		{Question: "1+1", Expected: "2", Weight: 1, TimedOut: true, DurationMS: 3000},
+		{Question: "2+2", Expected: "4", Weight: 1, Answer: "4", Answered: true, Correct: true, Credit: 1, Points: 1, DurationMS: 1000},
+		{Question: "3+3", Expected: "6", Weight: 1},
+	}
+	if !reflect.DeepEqual(report.Questions, want) {
+		t.Errorf("newQuizReport returned questions %+v, want %+v", report.Questions, want)
+	}
+	if report.Score != 1 {
+		t.Errorf("newQuizReport returned score %d, want 1", report.Score)
+	}
+}
+
+func TestReportWriters(t *testing.T) {
+	questions := []question{
+		{kind: textQuestion, question: "Capital of France?", answers: []string{"Paris"}, category: "geography", tags: []string{"europe"}, weight: 2},
+		{kind: textQuestion, question: "Capital of Japan?", answers: []string{"Tokyo"}, category: "geography", weight: 1, hint: "Starts with T"},
+		{kind: textQuestion, question: "5+5", answers: []string{"10"}, weight: 1},
+		{kind: choiceQuestion, question: "Primes?", options: []option{{"2", true}, {"4", false}, {"5", true}}, category: "maths", weight: 1},
+		{kind: textQuestion, question: "7*6", answers: []string{"42"}, category: "maths", weight: 1},
+	}
+	result := quizResult{hintPenalty: 0.5}
+	result.addAnswer(questions[0], "Paris", 1500*time.Millisecond, false)
+	result.addAnswer(questions[1], "Tokyo", 2250*time.Millisecond, true)
+	result.addAnswer(questions[2], "11", 500*time.Millisecond, false)
+	result.addTimeout(questions[3], 10*time.Second)
+	// The last question ends without being answered, like a question which is won by another player.
+	result.addUnanswered(questions[4], 0)
+	result.outOfTime = true
+	config := quizConfig{problemsPath: "/banks/capitals.yaml", passMark: 75}
+	report := newQuizReport(config, questions, result, 14250*time.Millisecond)
+
+	testCases := []struct {
+		format     string
+		goldenFile string
+	}{
+		{format: "json", goldenFile: "report.golden.json"},
+		{format: "junit", goldenFile: "report.golden.xml"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := formatToReportWriter[tc.format](&out, report); err != nil {
+				t.Fatalf("%s report writer returned unexpected err: %s", tc.format, err)
+			}
+
+			goldenPath := filepath.Join("test_data", tc.goldenFile)
+			if *update {
+				mustWriteFile(t, goldenPath, out.String())
+			}
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("failed to read %s: %s", goldenPath, err)
+			}
+			if out.String() != string(want) {
+				t.Errorf("%s report writer wrote:\n%s\nwant:\n%s", tc.format, out.String(), want)
+			}
+		})
+	}
+}
+
+func TestJUnitSeconds(t *testing.T) {
+	testCases := []struct {
+		ms   int64
+		want string
+	}{
+		{ms: 0, want: "0.000"},
+		{ms: 7, want: "0.007"},
+		{ms: 1500, want: "1.500"},
+		{ms: 61234, want: "61.234"},
+	}
+
+	for _, tc := range testCases {
+		if got := junitSeconds(tc.ms); got != tc.want {
+			t.Errorf("junitSeconds(%d) = %q, want %q", tc.ms, got, tc.want)
+		}
+	}
+}
+
+// TestMainExitCode runs the quiz in a subprocess, since main exits with the code which is being tested.
+func TestMainExitCode(t *testing.T) {
+	if args := os.Getenv("QUIZ_TEST_MAIN_ARGS"); args != "" {
+		os.Args = append([]string{"quiz"}, strings.Fields(args)...)
+		main()
+		os.Exit(0)
+	}
+
+	problemsPath := filepath.Join(t.TempDir(), "problems.csv")
+	mustWriteFile(t, problemsPath, "5+5,10\n7+3,10\n1+1,2\n1+2,3\n")
+
+	testCases := []struct {
+		name     string
+		passMark string
+		wantCode int
+	}{
+		{
+			name:     "score at pass mark passes",
+			passMark: "50",
+			wantCode: 0,
+		},
+		{
+			name:     "score below pass mark fails",
+			passMark: "75",
+			wantCode: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestMainExitCode$")
+			cmd.Env = append(os.Environ(), "QUIZ_TEST_MAIN_ARGS=-problems "+problemsPath+" -record=false -output json -pass-mark "+tc.passMark)
+			cmd.Stdin = strings.NewReader("\n10\n10\n3\n4\n")
+			out, err := cmd.Output()
+
+			code := 0
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatalf("failed to run quiz: %s", err)
+			}
+			if code != tc.wantCode {
+				t.Errorf("quiz with -pass-mark %s exited with code %d, want %d: %s", tc.passMark, code, tc.wantCode, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// leitnerIntervals are how long a question waits before it's due for review again after being answered correctly,
+// indexed by the Leitner box that the question is in. A question moves up a box each time it's answered correctly and
+// back to the first box whenever it's answered wrongly, so questions which are often wrong are asked the most.
+var leitnerIntervals = []time.Duration{
+	0,
+	24 * time.Hour,
+	3 * 24 * time.Hour,
+	7 * 24 * time.Hour,
+	14 * 24 * time.Hour,
+	30 * 24 * time.Hour,
+}
+
+// leitnerState is where a question is in the Leitner system. A question which has never been answered has no state.
+type leitnerState struct {
+	// box is the index into leitnerIntervals of the box that the question is in.
+	box       int
+	lastAsked time.Time
+}
+
+func (s leitnerState) due() time.Time {
+	return s.lastAsked.Add(leitnerIntervals[s.box])
+}
+
+// leitnerStates replays the answers in attempts, which must be in the order that they were recorded, to work out the
+// current state of each question that has been answered. The states are keyed by question text.
+func leitnerStates(attempts []attempt) map[string]leitnerState {
+	states := map[string]leitnerState{}
+	for _, a := range attempts {
+		for _, answer := range a.Answers {
+			state, seen := states[answer.Question]
+			switch {
+			case !answer.Correct:
+				state.box = 0
+			case seen && state.box < len(leitnerIntervals)-1:
+				state.box++
+			case !seen:
+				// A question which is answered correctly the first time that it's seen doesn't need to be asked
+				// again in the same session.
+				state.box = 1
+			}
+			state.lastAsked = a.Time
+			states[answer.Question] = state
+		}
+	}
+	return states
+}
+
+// dueQuestions returns the questions which are due for review at now, up to limit questions if limit is positive. The
+// questions in the lowest box are returned first, followed by the questions which have never been answered and then the
+// rest, with ties broken by how overdue the question is. If no questions are due, then the time that the next question
+// will be due is returned.
+func dueQuestions(questions []question, states map[string]leitnerState, now time.Time, limit int) ([]question, time.Time) {
+	type candidate struct {
+		question question
+		state    leitnerState
+		seen     bool
+	}
+
+	var due []candidate
+	var nextDue time.Time
+	for _, q := range questions {
+		state, seen := states[q.question]
+		if seen && state.due().After(now) {
+			if nextDue.IsZero() || state.due().Before(nextDue) {
+				nextDue = state.due()
+			}
+			continue
+		}
+		due = append(due, candidate{question: q, state: state, seen: seen})
+	}
+
+	// rank orders the first box before unseen questions and unseen questions before the other boxes.
+	rank := func(c candidate) int {
+		switch {
+		case !c.seen:
+			return 1
+		case c.state.box == 0:
+			return 0
+		default:
+			return c.state.box + 1
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		if rank(due[i]) != rank(due[j]) {
+			return rank(due[i]) < rank(due[j])
+		}
+		return due[i].state.due().Before(due[j].state.due())
+	})
+
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	dueQuestions := make([]question, len(due))
+	for i, c := range due {
+		dueQuestions[i] = c.question
+	}
+	return dueQuestions, nextDue
+}
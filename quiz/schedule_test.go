@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDueQuestions(t *testing.T) {
+	now := time.Date(2022, 6, 20, 12, 0, 0, 0, time.UTC)
+	questions := []question{
+		newTestQuestion("always right", "1"),
+		newTestQuestion("new", "2"),
+		newTestQuestion("wrong last time", "3"),
+		newTestQuestion("right after being wrong", "4"),
+	}
+	attempts := []attempt{
+		{
+			Time: now.Add(-4 * 24 * time.Hour),
+			Answers: []attemptAnswer{
+				{Question: "always right", Correct: true},
+				{Question: "wrong last time", Correct: true},
+				{Question: "right after being wrong", Correct: false},
+			},
+		},
+		{
+			Time: now.Add(-2 * 24 * time.Hour),
+			Answers: []attemptAnswer{
+				{Question: "always right", Correct: true},
+				{Question: "wrong last time", Correct: false},
+				{Question: "right after being wrong", Correct: true},
+			},
+		},
+	}
+
+	got, _ := dueQuestions(questions, leitnerStates(attempts), now, 0)
+
+	// "always right" is in the third box so isn't due for 3 days after it was last asked.
+	want := []string{"wrong last time", "new", "right after being wrong"}
+	if len(got) != len(want) {
+		t.Fatalf("dueQuestions returned %d questions, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].question != want[i] {
+			t.Errorf("dueQuestions()[%d] = %q, want %q", i, got[i].question, want[i])
+		}
+	}
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed quiz_template.html
+var quizTemplate string
+
+const sessionCookie = "quiz_session"
+
+// sessionLifetime is how long a session is kept for after it is started.
+const sessionLifetime = 24 * time.Hour
+
+// serveQuiz serves the quiz described by config over HTTP on the given port.
+func serveQuiz(config quizConfig, port uint) error {
+	questions, err := loadQuestions(config)
+	if err != nil {
+		return err
+	}
+
+	address := fmt.Sprintf(":%d", port)
+	log.Printf("Serving quiz of %d questions on %s.", len(questions), address)
+	if err := http.ListenAndServe(address, mustNewQuizServer(questions, config)); err != nil {
+		return fmt.Errorf("listen and serve on %q: %w", address, err)
+	}
+	return nil
+}
+
+// quizServer serves a quiz as HTML pages and as a JSON API. Each person taking the quiz has their own session which
+// holds their answers and the deadlines that they must answer by. Answers, hints and time limits are handled in the same
+// way as they are in the terminal, so the same answers to the same questions get the same results.
+type quizServer struct {
+	questions []question
+	config    quizConfig
+	quizTmpl  *template.Template
+	mux       *http.ServeMux
+	now       func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	rng      *mathrand.Rand
+}
+
+func mustNewQuizServer(questions []question, config quizConfig) *quizServer {
+	quizTmpl, err := template.New("quiz").Parse(quizTemplate)
+	if err != nil {
+		panic(fmt.Errorf("parse quiz template: %w", err))
+	}
+	s := &quizServer{
+		questions: questions,
+		config:    config,
+		quizTmpl:  quizTmpl,
+		mux:       http.NewServeMux(),
+		now:       time.Now,
+		sessions:  map[string]*session{},
+		rng:       mathrand.New(mathrand.NewSource(config.seed)),
+	}
+	s.mux.HandleFunc("/", s.handlePage)
+	s.mux.HandleFunc("/start", s.handleStart)
+	s.mux.HandleFunc("/answer", s.handleAnswer)
+	s.mux.HandleFunc("/api/sessions", s.handleAPICreateSession)
+	s.mux.HandleFunc("/api/sessions/", s.handleAPISession)
+	return s
+}
+
+func (s *quizServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// session is the state of a single run of the quiz.
+type session struct {
+	id        string
+	questions []question
+	started   time.Time
+	// deadline is when answers stop being accepted or zero if there is no time limit.
+	deadline time.Time
+	// lastAnswered is when the last answer was accepted or the last question timed out, which is when the next
+	// question was shown.
+	lastAnswered time.Time
+	// questionTimeLimit is how long each question can be answered within or zero if there is no limit.
+	questionTimeLimit time.Duration
+	// hinted is whether the hint to the next unanswered question has been shown.
+	hinted  bool
+	answers []string
+	result  quizResult
+	// recorded is whether the session has been recorded to the history file.
+	recorded bool
+}
+
+// submit scores each of answers in turn against the next unanswered question, stopping once all of the questions have
+// been answered or the deadline has passed. Answering with ? shows the hint to the question instead. If number isn't 0,
+// then the answers are only accepted if the next unanswered question has that number, so that an answer which arrives
+// after its question timed out isn't taken as the answer to the next one. It returns the number of answers which were
+// accepted.
+func (s *session) submit(number int, answers []string, now time.Time) int {
+	s.skipTimedOut(now)
+	if number != 0 && number != len(s.answers)+1 {
+		return 0
+	}
+	accepted := 0
+	for _, answer := range answers {
+		if s.finished(now) {
+			break
+		}
+		q := s.questions[len(s.answers)]
+		if answer == hintRequest {
+			s.hinted = s.hinted || q.hint != ""
+			continue
+		}
+		s.result.addAnswer(q, answer, now.Sub(s.lastAnswered), s.hinted)
+		s.lastAnswered = now
+		s.answers = append(s.answers, answer)
+		s.hinted = false
+		accepted++
+	}
+	return accepted
+}
+
+// skipTimedOut scores each question which wasn't answered within the question time limit as wrong, in the same way that
+// the terminal skips to the next question when the time limit is reached.
+func (s *session) skipTimedOut(now time.Time) {
+	if s.questionTimeLimit == 0 {
+		return
+	}
+	for len(s.answers) < len(s.questions) {
+		questionDeadline := s.questionDeadline()
+		// The quiz ended before the question's time ran out if it reached its own deadline first.
+		if now.Before(questionDeadline) || s.outOfTime(questionDeadline) {
+			return
+		}
+		s.result.addTimeout(s.questions[len(s.answers)], s.questionTimeLimit)
+		s.lastAnswered = questionDeadline
+		s.answers = append(s.answers, "")
+		s.hinted = false
+	}
+}
+
+// questionDeadline returns when the next unanswered question times out or zero if there is no question time limit.
+func (s *session) questionDeadline() time.Time {
+	if s.questionTimeLimit == 0 {
+		return time.Time{}
+	}
+	return s.lastAnswered.Add(s.questionTimeLimit)
+}
+
+// duration returns how long the session took to finish.
+func (s *session) duration(now time.Time) time.Duration {
+	if s.outOfTime(now) {
+		return s.deadline.Sub(s.started)
+	}
+	return s.lastAnswered.Sub(s.started)
+}
+
+func (s *session) finished(now time.Time) bool {
+	return len(s.answers) == len(s.questions) || s.outOfTime(now)
+}
+
+func (s *session) outOfTime(now time.Time) bool {
+	return !s.deadline.IsZero() && !now.Before(s.deadline)
+}
+
+func (s *quizServer) newSession() *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for id, sess := range s.sessions {
+		if now.Sub(sess.started) > sessionLifetime {
+			s.recordIfFinished(sess, now)
+			delete(s.sessions, id)
+		}
+	}
+
+	sess := &session{
+		id:                newSessionID(),
+		questions:         prepareQuestions(s.questions, s.config, s.rng),
+		started:           now,
+		lastAnswered:      now,
+		questionTimeLimit: s.config.perQuestionTimeout,
+		result:            quizResult{hintPenalty: s.config.hintPenalty},
+	}
+	if s.config.timeout > 0 {
+		sess.deadline = now.Add(s.config.timeout)
+	}
+	s.sessions[sess.id] = sess
+	return sess
+}
+
+// numSessionQuestions returns the number of questions which each session asks, which is less than the number of
+// questions in the quiz if they're sampled.
+func (s *quizServer) numSessionQuestions() int {
+	if s.config.sample > 0 && s.config.sample < len(s.questions) {
+		return s.config.sample
+	}
+	return len(s.questions)
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// withSession calls f with the session with the given ID while holding the server's lock, after skipping the
+// questions which have timed out, and then records the session if it has finished. It returns false if there is no such
+// session.
+func (s *quizServer) withSession(id string, f func(*session)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return false
+	}
+	sess.skipTimedOut(s.now())
+	f(sess)
+	s.recordIfFinished(sess, s.now())
+	return true
+}
+
+// recordIfFinished appends the session to the history file if it has finished and hasn't been recorded already, in the
+// same way that runQuiz records an attempt. The server's lock must be held.
+func (s *quizServer) recordIfFinished(sess *session, now time.Time) {
+	if s.config.historyPath == "" || sess.recorded || !sess.finished(now) {
+		return
+	}
+	sess.recorded = true
+	attempt := newAttempt(sess.started, s.config.bankName(), len(sess.questions), sess.result)
+	if err := appendAttempt(s.config.historyPath, attempt); err != nil {
+		log.Println(fmt.Errorf("record attempt: %w", err))
+	}
+}
+
+// pageData is the data that the quiz template is executed with.
+type pageData struct {
+	Started   bool
+	Finished  bool
+	OutOfTime bool
+	TimeLimit time.Duration
+	Remaining time.Duration
+	// QuestionTimeLimit and QuestionRemaining are the time limit for each question and the time left to answer the
+	// current one.
+	QuestionTimeLimit time.Duration
+	QuestionRemaining time.Duration
+	Total             int
+	// Report is the results of a finished session as they're written in the terminal.
+	Report   string
+	Number   int
+	Question apiQuestion
+	Hint     string
+}
+
+func (s *quizServer) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	data := pageData{Total: s.numSessionQuestions(), TimeLimit: s.config.timeout, QuestionTimeLimit: s.config.perQuestionTimeout}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		s.withSession(cookie.Value, func(sess *session) {
+			now := s.now()
+			data.Started = true
+			data.Total = len(sess.questions)
+			data.Finished = sess.finished(now)
+			data.OutOfTime = sess.outOfTime(now)
+			data.Number = len(sess.answers) + 1
+			if !sess.deadline.IsZero() {
+				data.Remaining = sess.deadline.Sub(now).Round(time.Second)
+			}
+			if data.Finished {
+				var report strings.Builder
+				if err := writeTextReport(&report, newQuizReport(s.config, sess.questions, sess.result, sess.duration(now))); err != nil {
+					log.Println(fmt.Errorf("write text report: %w", err))
+				}
+				data.Report = report.String()
+				return
+			}
+			q := sess.questions[len(sess.answers)]
+			data.Question = newAPIQuestion(len(sess.answers)+1, q)
+			if sess.hinted {
+				data.Hint = q.hint
+			}
+			if questionDeadline := sess.questionDeadline(); !questionDeadline.IsZero() {
+				data.QuestionRemaining = questionDeadline.Sub(now).Round(time.Second)
+			}
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.quizTmpl.Execute(w, data); err != nil {
+		log.Println(fmt.Errorf("execute quiz template: %w", err))
+	}
+}
+
+func (s *quizServer) handleStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	sess := s.newSession()
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: sess.id, Path: "/", HttpOnly: true})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (s *quizServer) handleAnswer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Form could not be parsed.", http.StatusBadRequest)
+		return
+	}
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	// The form includes the number of the question that it answers so that an answer which is submitted after its
+	// question timed out is ignored.
+	number := 0
+	if value := r.PostForm.Get("number"); value != "" {
+		if number, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Form number must be an integer.", http.StatusBadRequest)
+			return
+		}
+	}
+	// Multiple choice questions with several correct options submit one value per checked option.
+	answer := strings.Join(r.PostForm["answer"], ",")
+	s.withSession(cookie.Value, func(sess *session) {
+		sess.submit(number, []string{strings.TrimSpace(answer)}, s.now())
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// apiQuestion is a question as it is presented to the person taking the quiz, without its answers.
+type apiQuestion struct {
+	Number   int         `json:"number"`
+	Type     string      `json:"type"`
+	Question string      `json:"question"`
+	Multiple bool        `json:"multiple,omitempty"`
+	Options  []apiOption `json:"options,omitempty"`
+	HasHint  bool        `json:"has_hint,omitempty"`
+}
+
+type apiOption struct {
+	Letter string `json:"letter"`
+	Text   string `json:"text"`
+}
+
+func newAPIQuestion(number int, q question) apiQuestion {
+	apiQ := apiQuestion{
+		Number:   number,
+		Type:     q.kind,
+		Question: q.question,
+		Multiple: q.numCorrectOptions() > 1,
+		HasHint:  q.hint != "",
+	}
+	for i, option := range q.options {
+		apiQ.Options = append(apiQ.Options, apiOption{Letter: optionLetter(i), Text: option.text})
+	}
+	return apiQ
+}
+
+// apiSession is the state of a session as it is returned by the JSON API. Answered includes the questions which timed
+// out. Hint is the hint to the next unanswered question if it has been asked for. Report is the same as the report
+// written by -output json and is only included once the session has finished.
+type apiSession struct {
+	ID               string        `json:"id"`
+	Deadline         *time.Time    `json:"deadline,omitempty"`
+	QuestionDeadline *time.Time    `json:"question_deadline,omitempty"`
+	Questions        []apiQuestion `json:"questions"`
+	Answered         int           `json:"answered"`
+	Finished         bool          `json:"finished"`
+	OutOfTime        bool          `json:"out_of_time"`
+	Score            int           `json:"score"`
+	Total            int           `json:"total"`
+	Hint             string        `json:"hint,omitempty"`
+	Report           *quizReport   `json:"report,omitempty"`
+}
+
+func newAPISession(sess *session, config quizConfig, now time.Time) apiSession {
+	apiSess := apiSession{
+		ID:        sess.id,
+		Answered:  len(sess.answers),
+		Finished:  sess.finished(now),
+		OutOfTime: sess.outOfTime(now),
+		Score:     sess.result.score,
+		Total:     len(sess.questions),
+	}
+	if !sess.deadline.IsZero() {
+		deadline := sess.deadline
+		apiSess.Deadline = &deadline
+	}
+	if apiSess.Finished {
+		report := newQuizReport(config, sess.questions, sess.result, sess.duration(now))
+		apiSess.Report = &report
+	} else {
+		if questionDeadline := sess.questionDeadline(); !questionDeadline.IsZero() {
+			apiSess.QuestionDeadline = &questionDeadline
+		}
+		if sess.hinted {
+			apiSess.Hint = sess.questions[len(sess.answers)].hint
+		}
+	}
+	for i, q := range sess.questions {
+		apiSess.Questions = append(apiSess.Questions, newAPIQuestion(i+1, q))
+	}
+	return apiSess
+}
+
+// handleAPICreateSession starts a new session and returns its ID and questions.
+//
+//	POST /api/sessions
+func (s *quizServer) handleAPICreateSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	sess := s.newSession()
+	writeJSON(w, http.StatusCreated, newAPISession(sess, s.config, s.now()))
+}
+
+// handleAPISession returns the state of a session or submits answers to it.
+//
+//	GET /api/sessions/{id}
+//	POST /api/sessions/{id}/answers {"answers": ["..."], "number": 1}
+//
+// Submitted answers are given to the session's unanswered questions in order and an answer of ? asks for the hint to
+// the next question. Answers which are submitted after all of the questions have been answered or the deadline has
+// passed are ignored, as are answers which are submitted with a number other than that of the next unanswered question.
+// The number can be left out to answer whichever question is next.
+func (s *quizServer) handleAPISession(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/sessions/"), "/")
+	switch action {
+	case "":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		var apiSess apiSession
+		found := s.withSession(id, func(sess *session) {
+			apiSess = newAPISession(sess, s.config, s.now())
+		})
+		if !found {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("No session found with id: %s", id))
+			return
+		}
+		writeJSON(w, http.StatusOK, apiSess)
+
+	case "answers":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		var req struct {
+			Answers []string `json:"answers"`
+			Number  int      `json:"number"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Request is not valid JSON.")
+			return
+		}
+		var apiSess apiSession
+		found := s.withSession(id, func(sess *session) {
+			for i, answer := range req.Answers {
+				req.Answers[i] = strings.TrimSpace(answer)
+			}
+			now := s.now()
+			sess.submit(req.Number, req.Answers, now)
+			apiSess = newAPISession(sess, s.config, now)
+		})
+		if !found {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("No session found with id: %s", id))
+			return
+		}
+		writeJSON(w, http.StatusOK, apiSess)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowedMethod string) {
+	w.Header().Add("Allow", allowedMethod)
+	http.Error(w, fmt.Sprintf("Method not allowed, use %s.", allowedMethod), http.StatusMethodNotAllowed)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(fmt.Errorf("encode response %+v to JSON: %w", v, err))
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{
+		Error: msg,
+	})
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIScoresAnswersLikeRunQuiz(t *testing.T) {
+	questions := []question{
+		newTestQuestion("5+5", "10"),
+		newTestQuestion("7+3", "10"),
+		{kind: trueFalseQuestion, question: "Go is compiled", answers: []string{"true"}},
+		{kind: choiceQuestion, question: "Primes?", options: []option{{"2", true}, {"4", false}, {"5", true}}},
+	}
+	server := mustNewQuizServer(questions, quizConfig{})
+
+	created := mustDoAPIRequest(t, server, http.MethodPost, "/api/sessions", "", http.StatusCreated)
+	if len(created.Questions) != len(questions) {
+		t.Fatalf("POST /api/sessions returned %d questions, want %d", len(created.Questions), len(questions))
+	}
+
+	got := mustDoAPIRequest(t, server, http.MethodPost, "/api/sessions/"+created.ID+"/answers", `{"answers": ["10", " 11 ", "y"]}`, http.StatusOK)
+	if got.Answered != 3 || got.Finished || got.Score != 2 {
+		t.Errorf("after submitting 3 answers got answered: %d, finished: %t, score: %d, want answered: 3, finished: false, score: 2", got.Answered, got.Finished, got.Score)
+	}
+
+	got = mustDoAPIRequest(t, server, http.MethodPost, "/api/sessions/"+created.ID+"/answers", `{"answers": ["c,a", "extra"]}`, http.StatusOK)
+	if got.Answered != 4 || !got.Finished || got.Score != 3 {
+		t.Errorf("after submitting final answer got answered: %d, finished: %t, score: %d, want answered: 4, finished: true, score: 3", got.Answered, got.Finished, got.Score)
+	}
+}
+
+func TestAPIHintsAndReport(t *testing.T) {
+	questions := []question{
+		{kind: textQuestion, question: "5+5", answers: []string{"10"}, match: matchRule{mode: matchExact}, weight: 2, hint: "Two fives"},
+		newTestQuestion("1+1", "2"),
+	}
+	server := mustNewQuizServer(questions, quizConfig{hintPenalty: 0.25})
+
+	created := mustDoAPIRequest(t, server, http.MethodPost, "/api/sessions", "", http.StatusCreated)
+	if !created.Questions[0].HasHint || created.Questions[1].HasHint {
+		t.Errorf("POST /api/sessions returned has_hint: %t, %t, want true, false", created.Questions[0].HasHint, created.Questions[1].HasHint)
+	}
+
+	got := mustDoAPIRequest(t, server, http.MethodPost, "/api/sessions/"+created.ID+"/answers", `{"answers": ["?"]}`, http.StatusOK)
+	if got.Answered != 0 || got.Hint != "Two fives" {
+		t.Errorf("after asking for the hint got answered: %d, hint: %q, want answered: 0, hint: %q", got.Answered, got.Hint, "Two fives")
+	}
+
+	got = mustDoAPIRequest(t, server, http.MethodPost, "/api/sessions/"+created.ID+"/answers", `{"answers": ["10"], "number": 2}`, http.StatusOK)
+	if got.Answered != 0 {
+		t.Errorf("after answering the wrong question number got answered: %d, want 0", got.Answered)
+	}
+
+	got = mustDoAPIRequest(t, server, http.MethodPost, "/api/sessions/"+created.ID+"/answers", `{"answers": ["10", "2"], "number": 1}`, http.StatusOK)
+	if got.Report == nil {
+		t.Fatalf("finished session has no report")
+	}
+	if got.Report.WeightedScore != 2.5 || got.Report.MaxWeightedScore != 3 || !got.Report.Questions[0].HintUsed {
+		t.Errorf("report has weighted score: %g / %g, hint used: %t, want weighted score: 2.5 / 3, hint used: true", got.Report.WeightedScore, got.Report.MaxWeightedScore, got.Report.Questions[0].HintUsed)
+	}
+}
+
+func TestAPIIgnoresAnswersAfterDeadline(t *testing.T) {
+	server := mustNewQuizServer([]question{newTestQuestion("5+5", "10"), newTestQuestion("1+1", "2")}, quizConfig{timeout: time.Minute})
+	now := time.Now()
+	server.now = func() time.Time { return now }
+
+	created := mustDoAPIRequest(t, server, http.MethodPost, "/api/sessions", "", http.StatusCreated)
+	mustDoAPIRequest(t, server, http.MethodPost, "/api/sessions/"+created.ID+"/answers", `{"answers": ["10"]}`, http.StatusOK)
+
+	now = now.Add(time.Minute)
+	got := mustDoAPIRequest(t, server, http.MethodPost, "/api/sessions/"+created.ID+"/answers", `{"answers": ["2"]}`, http.StatusOK)
+	if got.Answered != 1 || !got.OutOfTime || got.Score != 1 {
+		t.Errorf("after deadline got answered: %d, out of time: %t, score: %d, want answered: 1, out of time: true, score: 1", got.Answered, got.OutOfTime, got.Score)
+	}
+}
+
+func TestServerRecordsFinishedSessions(t *testing.T) {
+	historyPath := filepath.Join(t.TempDir(), "history.jsonl")
+	questions := []question{newTestQuestion("5+5", "10"), newTestQuestion("1+1", "2")}
+	server := mustNewQuizServer(questions, quizConfig{problemsPath: "problems.csv", timeout: time.Minute, historyPath: historyPath})
+	now := time.Now()
+	server.now = func() time.Time { return now }
+
+	finished := mustDoAPIRequest(t, server, http.MethodPost, "/api/sessions", "", http.StatusCreated)
+	mustDoAPIRequest(t, server, http.MethodPost, "/api/sessions/"+finished.ID+"/answers", `{"answers": ["10"]}`, http.StatusOK)
+	if attempts, err := readAttempts(historyPath, ""); err != nil || len(attempts) != 0 {
+		t.Fatalf("after answering 1 of 2 questions readAttempts = %+v, %v, want no attempts", attempts, err)
+	}
+	mustDoAPIRequest(t, server, http.MethodPost, "/api/sessions/"+finished.ID+"/answers", `{"answers": ["3"]}`, http.StatusOK)
+	mustDoAPIRequest(t, server, http.MethodGet, "/api/sessions/"+finished.ID, "", http.StatusOK)
+
+	outOfTime := mustDoAPIRequest(t, server, http.MethodPost, "/api/sessions", "", http.StatusCreated)
+	mustDoAPIRequest(t, server, http.MethodPost, "/api/sessions/"+outOfTime.ID+"/answers", `{"answers": ["10"]}`, http.StatusOK)
+	now = now.Add(time.Minute)
+	mustDoAPIRequest(t, server, http.MethodGet, "/api/sessions/"+outOfTime.ID, "", http.StatusOK)
+
+	attempts, err := readAttempts(historyPath, "")
+	if err != nil {
+		t.Fatalf("readAttempts returned unexpected err: %s", err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("readAttempts returned %d attempts, want 2: %+v", len(attempts), attempts)
+	}
+	bank := bankName("problems.csv")
+	for i, want := range []struct{ score, answers int }{{1, 2}, {1, 1}} {
+		if got := attempts[i]; got.Bank != bank || got.Score != want.score || got.Total != 2 || len(got.Answers) != want.answers {
+			t.Errorf("attempt %d has bank: %q, score: %d, total: %d, answers: %d, want bank: %q, score: %d, total: 2, answers: %d", i, got.Bank, got.Score, got.Total, len(got.Answers), bank, want.score, want.answers)
+		}
+	}
+}
+
+func TestPageShowsNumberOfSampledQuestions(t *testing.T) {
+	questions := []question{newTestQuestion("1+1", "2"), newTestQuestion("2+2", "4"), newTestQuestion("3+3", "6"), newTestQuestion("4+4", "8")}
+	server := mustNewQuizServer(questions, quizConfig{sample: 2})
+
+	if body := mustGetPage(t, server, nil); !strings.Contains(body, "2 questions") {
+		t.Errorf("GET / before starting returned %q, want it to contain %q", body, "2 questions")
+	}
+
+	cookie := mustStartSession(t, server)
+	if body := mustGetPage(t, server, cookie); !strings.Contains(body, "Question 1 of 2") {
+		t.Errorf("GET / after starting returned %q, want it to contain %q", body, "Question 1 of 2")
+	}
+
+	mustDoPageRequest(t, server, http.MethodPost, "/answer", cookie, "answer=2", http.StatusSeeOther)
+	mustDoPageRequest(t, server, http.MethodPost, "/answer", cookie, "answer=0", http.StatusSeeOther)
+	if body := mustGetPage(t, server, cookie); !strings.Contains(body, " / 2\nWeighted score: ") {
+		t.Errorf("GET / after finishing returned %q, want the score to be out of 2", body)
+	}
+}
+
+func TestPageResultsMatchRunQuiz(t *testing.T) {
+	problemsPath := filepath.Join(t.TempDir(), "problems.yaml")
+	mustWriteFile(t, problemsPath, `- question: Capital of France?
+  answer: Paris
+  hint: Starts with P
+  weight: 2
+  category: geography
+- question: 5+5
+  answer: 10
+- question: Primes?
+  type: choice
+  options: [2, 4, 5]
+  answers: [a, c]
+  category: maths
+`)
+	config := quizConfig{problemsPath: problemsPath, output: "text", hintPenalty: 0.5, passMark: 50}
+
+	var cliOut bytes.Buffer
+	if _, err := runQuiz(config, strings.NewReader("\n?\nParis\n11\na,c\n"), &cliOut, &cliOut); err != nil {
+		t.Fatalf("runQuiz returned unexpected err: %s", err)
+	}
+	wantReport := cliOut.String()[strings.Index(cliOut.String(), "Score: "):]
+
+	questions, err := loadQuestions(config)
+	if err != nil {
+		t.Fatalf("loadQuestions returned unexpected err: %s", err)
+	}
+	server := mustNewQuizServer(questions, config)
+	cookie := mustStartSession(t, server)
+
+	body := mustGetPage(t, server, cookie)
+	for _, want := range []string{"Question 1 of 3", "Capital of France?", "Show hint"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("GET / after starting returned %q, want it to contain %q", body, want)
+		}
+	}
+
+	mustDoPageRequest(t, server, http.MethodPost, "/answer", cookie, "number=1&answer=%3F", http.StatusSeeOther)
+	body = mustGetPage(t, server, cookie)
+	if !strings.Contains(body, "Hint: Starts with P") || strings.Contains(body, "Show hint") {
+		t.Errorf("GET / after asking for the hint returned %q, want it to contain the hint and no hint button", body)
+	}
+
+	mustDoPageRequest(t, server, http.MethodPost, "/answer", cookie, "number=1&answer=Paris", http.StatusSeeOther)
+	mustDoPageRequest(t, server, http.MethodPost, "/answer", cookie, "number=2&answer=11", http.StatusSeeOther)
+	mustDoPageRequest(t, server, http.MethodPost, "/answer", cookie, "number=3&answer=a&answer=c", http.StatusSeeOther)
+
+	body = mustGetPage(t, server, cookie)
+	if !strings.Contains(body, "<pre>"+wantReport+"</pre>") {
+		t.Errorf("GET / after finishing returned %q, want it to contain the report written by runQuiz %q", body, wantReport)
+	}
+}
+
+func TestPageSkipsTimedOutQuestions(t *testing.T) {
+	questions := []question{newTestQuestion("1+1", "2"), newTestQuestion("2+2", "4"), newTestQuestion("3+3", "6")}
+	server := mustNewQuizServer(questions, quizConfig{perQuestionTimeout: 10 * time.Second})
+	now := time.Now()
+	server.now = func() time.Time { return now }
+	cookie := mustStartSession(t, server)
+
+	now = now.Add(25 * time.Second)
+	body := mustGetPage(t, server, cookie)
+	for _, want := range []string{"Question 3 of 3", "Time remaining for this question: 5s"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("GET / after 2 questions timed out returned %q, want it to contain %q", body, want)
+		}
+	}
+
+	// The answer to the first question arrives too late, so it mustn't be taken as the answer to the third.
+	mustDoPageRequest(t, server, http.MethodPost, "/answer", cookie, "number=1&answer=2", http.StatusSeeOther)
+	mustDoPageRequest(t, server, http.MethodPost, "/answer", cookie, "number=3&answer=6", http.StatusSeeOther)
+
+	body = mustGetPage(t, server, cookie)
+	for _, want := range []string{"Score: 1 / 3", "Timed out on:\n  1+1\n  2+2\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("GET / after finishing returned %q, want it to contain %q", body, want)
+		}
+	}
+}
+
+func TestPageHandlersRejectOtherMethods(t *testing.T) {
+	server := mustNewQuizServer([]question{newTestQuestion("1+1", "2")}, quizConfig{})
+	testCases := []struct {
+		method    string
+		path      string
+		wantAllow string
+	}{
+		{method: http.MethodPost, path: "/", wantAllow: http.MethodGet},
+		{method: http.MethodGet, path: "/start", wantAllow: http.MethodPost},
+		{method: http.MethodGet, path: "/answer", wantAllow: http.MethodPost},
+	}
+
+	for _, tc := range testCases {
+		rec := mustDoPageRequest(t, server, tc.method, tc.path, nil, "", http.StatusMethodNotAllowed)
+		if got := rec.Header().Get("Allow"); got != tc.wantAllow {
+			t.Errorf("%s %s returned Allow header %q, want %q", tc.method, tc.path, got, tc.wantAllow)
+		}
+	}
+}
+
+func TestAnswerWithoutSessionRedirectsToStart(t *testing.T) {
+	server := mustNewQuizServer([]question{newTestQuestion("1+1", "2")}, quizConfig{})
+	rec := mustDoPageRequest(t, server, http.MethodPost, "/answer", nil, "answer=2", http.StatusSeeOther)
+	if got := rec.Header().Get("Location"); got != "/" {
+		t.Errorf("POST /answer without a session redirected to %q, want %q", got, "/")
+	}
+}
+
+// mustStartSession starts a session through the HTML form and returns its cookie.
+func mustStartSession(t *testing.T, server http.Handler) *http.Cookie {
+	rec := mustDoPageRequest(t, server, http.MethodPost, "/start", nil, "", http.StatusSeeOther)
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == sessionCookie {
+			return cookie
+		}
+	}
+	t.Fatalf("POST /start didn't set the %s cookie", sessionCookie)
+	return nil
+}
+
+// mustGetPage returns the unescaped body of the quiz page.
+func mustGetPage(t *testing.T, server http.Handler, cookie *http.Cookie) string {
+	return html.UnescapeString(mustDoPageRequest(t, server, http.MethodGet, "/", cookie, "", http.StatusOK).Body.String())
+}
+
+func mustDoPageRequest(t *testing.T, server http.Handler, method string, path string, cookie *http.Cookie, form string, wantStatus int) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(form))
+	if form != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != wantStatus {
+		t.Fatalf("%s %s returned status %d, want %d: %s", method, path, rec.Code, wantStatus, rec.Body)
+	}
+	return rec
+}
+
+func mustDoAPIRequest(t *testing.T, server http.Handler, method string, path string, body string, wantStatus int) apiSession {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != wantStatus {
+		t.Fatalf("%s %s returned status %d, want %d: %s", method, path, rec.Code, wantStatus, rec.Body)
+	}
+	var sess apiSession
+	if err := json.NewDecoder(rec.Body).Decode(&sess); err != nil {
+		t.Fatalf("%s %s returned invalid JSON: %s", method, path, err)
+	}
+	return sess
+}
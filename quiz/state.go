@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const defaultStateFile = "quiz-state.json"
+
+// stateVersion is the version of the format that suspended quizzes are saved in. It must be incremented whenever the
+// format changes so that state files saved by an older version are rejected instead of being misread.
+const stateVersion = 2
+
+// savedQuiz is the progress through a suspended quiz as it is stored in a state file. The questions are stored in the
+// order that they're asked, along with the order of their options, so that a resumed quiz asks exactly the same
+// questions however they were chosen.
+type savedQuiz struct {
+	Version      int             `json:"version"`
+	Bank         string          `json:"bank"`
+	ProblemsPath string          `json:"problems_path,omitempty"`
+	Format       string          `json:"format,omitempty"`
+	Generator    *savedGenerator `json:"generator,omitempty"`
+	// Seed is the seed that template questions were expanded with.
+	Seed    int64     `json:"seed"`
+	Started time.Time `json:"started"`
+	// ElapsedMS is how long was spent on the quiz before it was suspended.
+	ElapsedMS int64 `json:"elapsed_ms"`
+	// TimeLeftMS is how much of the quiz's time limit was left when it was suspended or 0 if it has no limit.
+	TimeLeftMS           int64           `json:"time_left_ms,omitempty"`
+	PerQuestionTimeoutMS int64           `json:"per_question_timeout_ms,omitempty"`
+	HintPenalty          float64         `json:"hint_penalty"`
+	Questions            []savedQuestion `json:"questions"`
+	Answers              []savedAnswer   `json:"answers"`
+}
+
+type savedGenerator struct {
+	Operators  []string `json:"operators"`
+	Count      int      `json:"count"`
+	Difficulty int      `json:"difficulty"`
+	Seed       int64    `json:"seed"`
+}
+
+type savedQuestion struct {
+	// Index is the position of the question in the bank, which identifies it since the texts of questions aren't
+	// unique. The question is saved too so that a bank which has changed since the quiz was saved is rejected.
+	Index    int      `json:"index"`
+	Question string   `json:"question"`
+	Options  []string `json:"options,omitempty"`
+}
+
+type savedAnswer struct {
+	Answer    string `json:"answer,omitempty"`
+	Hinted    bool   `json:"hinted,omitempty"`
+	TimedOut  bool   `json:"timed_out,omitempty"`
+	ElapsedMS int64  `json:"elapsed_ms"`
+}
+
+func newSavedQuiz(config quizConfig, questions []question, result quizResult, started time.Time, elapsed time.Duration) savedQuiz {
+	s := savedQuiz{
+		Version:              stateVersion,
+		Bank:                 config.bankName(),
+		ProblemsPath:         bankName(config.problemsPath),
+		Format:               config.format,
+		Seed:                 config.seed,
+		Started:              started,
+		ElapsedMS:            elapsed.Milliseconds(),
+		PerQuestionTimeoutMS: config.perQuestionTimeout.Milliseconds(),
+		HintPenalty:          config.hintPenalty,
+	}
+	if config.generator != nil {
+		s.ProblemsPath = ""
+		s.Generator = &savedGenerator{
+			Operators:  config.generator.operators,
+			Count:      config.generator.count,
+			Difficulty: config.generator.difficulty,
+			Seed:       config.generator.seed,
+		}
+	}
+	if config.timeout > 0 {
+		// A quiz with no time left would have ended instead of being suspended, so at least a millisecond is kept to
+		// avoid it being mistaken for a quiz without a time limit.
+		s.TimeLeftMS = result.timeLeft.Milliseconds()
+		if s.TimeLeftMS < 1 {
+			s.TimeLeftMS = 1
+		}
+	}
+	for _, q := range questions {
+		saved := savedQuestion{Index: q.index, Question: q.question}
+		for _, o := range q.options {
+			saved.Options = append(saved.Options, o.text)
+		}
+		s.Questions = append(s.Questions, saved)
+	}
+	for _, given := range result.answers {
+		s.Answers = append(s.Answers, savedAnswer{
+			Answer:    given.answer,
+			Hinted:    given.hinted,
+			TimedOut:  given.timedOut,
+			ElapsedMS: given.elapsed.Milliseconds(),
+		})
+	}
+	return s
+}
+
+// writeSavedQuiz writes s to the state file at path, replacing it if it already exists.
+func writeSavedQuiz(path string, s savedQuiz) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode state: %s", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write state file: %s", err)
+	}
+	return nil
+}
+
+// readSavedQuiz reads the suspended quiz from the state file at path.
+func readSavedQuiz(path string) (savedQuiz, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return savedQuiz{}, fmt.Errorf("read state file: %s", err)
+	}
+	var version struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &version); err != nil {
+		return savedQuiz{}, fmt.Errorf("decode state file: %s", err)
+	}
+	if version.Version != stateVersion {
+		return savedQuiz{}, fmt.Errorf("unsupported state file version %d, expected %d", version.Version, stateVersion)
+	}
+	var s savedQuiz
+	if err := json.Unmarshal(data, &s); err != nil {
+		return savedQuiz{}, fmt.Errorf("decode state file: %s", err)
+	}
+	if len(s.Answers) > len(s.Questions) {
+		return savedQuiz{}, fmt.Errorf("state file has %d answers for %d questions", len(s.Answers), len(s.Questions))
+	}
+	return s, nil
+}
+
+// apply returns config with the options which the suspended quiz was started with, so that it's resumed in the same
+// way. The options which choose the questions are cleared because the questions to ask have already been chosen.
+func (s savedQuiz) apply(config quizConfig) quizConfig {
+	config.problemsPath = s.ProblemsPath
+	config.format = s.Format
+	config.seed = s.Seed
+	config.generator = nil
+	if s.Generator != nil {
+		config.generator = &generatorConfig{
+			operators:  s.Generator.Operators,
+			count:      s.Generator.Count,
+			difficulty: s.Generator.Difficulty,
+			seed:       s.Generator.Seed,
+		}
+	}
+	config.timeout = time.Duration(s.TimeLeftMS) * time.Millisecond
+	config.perQuestionTimeout = time.Duration(s.PerQuestionTimeoutMS) * time.Millisecond
+	config.hintPenalty = s.HintPenalty
+	config.category = ""
+	config.tags = nil
+	config.sample = 0
+	config.shuffle = false
+	config.shuffleOptions = false
+	config.practiceHistoryPath = ""
+	return config
+}
+
+// restore returns the questions which the suspended quiz asks, taken from bank in the order that they were saved in,
+// and the result of the answers which had already been given.
+func (s savedQuiz) restore(bank []question, hintPenalty float64) ([]question, quizResult, error) {
+	var questions []question
+	for _, saved := range s.Questions {
+		if saved.Index < 0 || saved.Index >= len(bank) || bank[saved.Index].question != saved.Question {
+			return nil, quizResult{}, fmt.Errorf("question %d %q is no longer in the problems file", saved.Index+1, saved.Question)
+		}
+		q, err := saved.reorderOptions(bank[saved.Index])
+		if err != nil {
+			return nil, quizResult{}, err
+		}
+		questions = append(questions, q)
+	}
+
+	result := quizResult{hintPenalty: hintPenalty}
+	for i, saved := range s.Answers {
+		elapsed := time.Duration(saved.ElapsedMS) * time.Millisecond
+		if saved.TimedOut {
+			result.addTimeout(questions[i], elapsed)
+		} else {
+			result.addAnswer(questions[i], saved.Answer, elapsed, saved.Hinted)
+		}
+	}
+	return questions, result, nil
+}
+
+// reorderOptions returns q with its options in the order that they were saved in.
+func (s savedQuestion) reorderOptions(q question) (question, error) {
+	if len(s.Options) != len(q.options) {
+		return question{}, fmt.Errorf("options of question %q have changed since it was saved", s.Question)
+	}
+	if len(s.Options) == 0 {
+		return q, nil
+	}
+	options := make([]option, 0, len(q.options))
+	for _, text := range s.Options {
+		found := false
+		for _, o := range q.options {
+			if o.text == text {
+				options = append(options, o)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return question{}, fmt.Errorf("options of question %q have changed since it was saved", s.Question)
+		}
+	}
+	q.options = options
+	return q, nil
+}
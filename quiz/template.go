@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxTemplateAttempts is the number of times that values are chosen for each question that a template expands into
+// before giving up on finding distinct questions with an answer that can be worked out.
+const maxTemplateAttempts = 10
+
+// questionTemplate describes the questions which a template question expands into. Each question is made by choosing a
+// random value for each variable and then replacing each {{expression}} placeholder in the question and hint with the
+// expression's value, and working out the answer from an expression of the variables.
+type questionTemplate struct {
+	vars     []templateVar
+	question []templatePart
+	hint     []templatePart
+	answer   expr
+	count    int
+}
+
+// templateVar is a variable of a template which takes a random integer value from min to max inclusive or, if values
+// is set, a random one of values.
+type templateVar struct {
+	name     string
+	min, max int
+	values   []float64
+}
+
+// templatePart is a part of a template's text which is either literal text or, if expr is set, a placeholder which is
+// replaced with the expression's value.
+type templatePart struct {
+	text string
+	expr *expr
+}
+
+// parseQuestionTemplate parses the template made of a question and hint with placeholders, the answer expression, the
+// spec of each variable and the number of questions to expand into.
+func parseQuestionTemplate(question, hint, answer string, vars map[string]string, count int) (*questionTemplate, error) {
+	if count < 1 {
+		return nil, fieldError{"count", fmt.Errorf("count must be at least 1, got %d", count)}
+	}
+	t := &questionTemplate{count: count}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	defined := map[string]bool{}
+	for _, name := range names {
+		v, err := parseTemplateVar(name, vars[name])
+		if err != nil {
+			return nil, fieldError{"vars", err}
+		}
+		t.vars = append(t.vars, v)
+		defined[name] = true
+	}
+
+	var err error
+	t.answer, err = parseExpr(answer)
+	if err != nil {
+		return nil, fieldError{"expr", err}
+	}
+	if err := checkVarsDefined(t.answer, defined); err != nil {
+		return nil, fieldError{"expr", err}
+	}
+	if t.question, err = parseTemplateText(question, defined); err != nil {
+		return nil, fieldError{"question", err}
+	}
+	if t.hint, err = parseTemplateText(hint, defined); err != nil {
+		return nil, fieldError{"hint", err}
+	}
+	return t, nil
+}
+
+// parseTemplateVar parses the spec of a variable, which is either an integer range like 1..10 or a comma separated list
+// of numbers like 2,3,5.
+func parseTemplateVar(name string, spec string) (templateVar, error) {
+	if !isValidName(name) {
+		return templateVar{}, fmt.Errorf("invalid variable name %q", name)
+	}
+	v := templateVar{name: name}
+	spec = strings.TrimSpace(spec)
+	if lo, hi, ok := strings.Cut(spec, ".."); ok {
+		var err error
+		if v.min, err = strconv.Atoi(strings.TrimSpace(lo)); err != nil {
+			return templateVar{}, fmt.Errorf("range %q of variable %s doesn't start with an integer", spec, name)
+		}
+		if v.max, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil {
+			return templateVar{}, fmt.Errorf("range %q of variable %s doesn't end with an integer", spec, name)
+		}
+		if v.min > v.max {
+			return templateVar{}, fmt.Errorf("range %q of variable %s is empty", spec, name)
+		}
+		return v, nil
+	}
+	for _, s := range strings.Split(spec, ",") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return templateVar{}, fmt.Errorf("value %q of variable %s is not a number, expected a range like 1..10 or a list like 2,3,5", s, name)
+		}
+		v.values = append(v.values, value)
+	}
+	return v, nil
+}
+
+func (v templateVar) choose(rng *rand.Rand) float64 {
+	if v.values != nil {
+		return v.values[rng.Intn(len(v.values))]
+	}
+	return float64(v.min + rng.Intn(v.max-v.min+1))
+}
+
+// parseTemplateText splits s into literal text and {{expression}} placeholders.
+func parseTemplateText(s string, defined map[string]bool) ([]templatePart, error) {
+	var parts []templatePart
+	for s != "" {
+		start := strings.Index(s, "{{")
+		if start == -1 {
+			parts = append(parts, templatePart{text: s})
+			break
+		}
+		end := strings.Index(s[start:], "}}")
+		if end == -1 {
+			return nil, errors.New("placeholder is missing its closing }}")
+		}
+		e, err := parseExpr(s[start+2 : start+end])
+		if err != nil {
+			return nil, err
+		}
+		if err := checkVarsDefined(e, defined); err != nil {
+			return nil, err
+		}
+		if start > 0 {
+			parts = append(parts, templatePart{text: s[:start]})
+		}
+		parts = append(parts, templatePart{expr: &e})
+		s = s[start+end+2:]
+	}
+	return parts, nil
+}
+
+func checkVarsDefined(e expr, defined map[string]bool) error {
+	for _, name := range e.vars {
+		if !defined[name] {
+			return fmt.Errorf("variable %s is not defined in vars", name)
+		}
+	}
+	return nil
+}
+
+// render returns the text made by replacing each placeholder in parts with its value.
+func render(parts []templatePart, values map[string]float64) (string, error) {
+	var b strings.Builder
+	for _, part := range parts {
+		if part.expr == nil {
+			b.WriteString(part.text)
+			continue
+		}
+		v, err := part.expr.eval(values)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(formatNumber(v))
+	}
+	return b.String(), nil
+}
+
+// expand returns up to t.count distinct questions made from the template question q. Fewer questions are returned if
+// the variables don't have enough values between them to make t.count distinct ones. Values which the answer or a
+// placeholder can't be worked out for, like those which would divide by zero, are skipped.
+func (t *questionTemplate) expand(q question, rng *rand.Rand) ([]question, error) {
+	var questions []question
+	seen := map[string]bool{}
+	var lastErr error
+	for attempt := 0; attempt < t.count*maxTemplateAttempts && len(questions) < t.count; attempt++ {
+		values := map[string]float64{}
+		for _, v := range t.vars {
+			values[v.name] = v.choose(rng)
+		}
+		text, err := render(t.question, values)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if seen[text] {
+			continue
+		}
+		hint, err := render(t.hint, values)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		answer, err := t.answer.eval(values)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		seen[text] = true
+
+		expanded := q
+		expanded.template = nil
+		expanded.question = text
+		expanded.hint = hint
+		expanded.answers = []string{formatNumber(answer)}
+		questions = append(questions, expanded)
+	}
+	if len(questions) == 0 {
+		return nil, fmt.Errorf("no values of the variables give a question with an answer: %s", lastErr)
+	}
+	return questions, nil
+}
+
+// expandTemplates replaces each template question in questions with the questions that it expands into, choosing the
+// values of their variables with rng so that the same seed always expands them in the same way.
+func expandTemplates(questions []question, rng *rand.Rand) ([]question, error) {
+	expanded := make([]question, 0, len(questions))
+	for _, q := range questions {
+		if q.template == nil {
+			expanded = append(expanded, q)
+			continue
+		}
+		templateQuestions, err := q.template.expand(q, rng)
+		if err != nil {
+			return nil, fmt.Errorf("expand template %q: %s", q.question, err)
+		}
+		expanded = append(expanded, templateQuestions...)
+	}
+	return expanded, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	testCases := []struct {
+		name string
+		expr string
+		vars map[string]float64
+		want float64
+	}{
+		{name: "precedence", expr: "1 + 2 * 3 - 4 / 2", want: 5},
+		{name: "parentheses", expr: "(1 + 2) * 3", want: 9},
+		{name: "power is right associative", expr: "2 ^ 3 ^ 2", want: 512},
+		{name: "unary minus", expr: "-a * -b", vars: map[string]float64{"a": 2, "b": 3}, want: 6},
+		{name: "modulo", expr: "a % 4", vars: map[string]float64{"a": 10}, want: 2},
+		{name: "functions", expr: "max(a, 2, b) + round(1 / 3, 2)", vars: map[string]float64{"a": 1, "b": 5}, want: 5.33},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := parseExpr(tc.expr)
+			if err != nil {
+				t.Fatalf("parseExpr(%q) returned unexpected err: %s", tc.expr, err)
+			}
+			got, err := e.eval(tc.vars)
+			if err != nil {
+				t.Fatalf("parseExpr(%q).eval(%v) returned unexpected err: %s", tc.expr, tc.vars, err)
+			}
+			if got != tc.want {
+				t.Errorf("parseExpr(%q).eval(%v) = %v, want %v", tc.expr, tc.vars, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	for _, s := range []string{"", "1 +", "(1 + 2", "2 $ 3", "foo(1)", "min(1)", "1 2"} {
+		if _, err := parseExpr(s); err == nil {
+			t.Errorf("parseExpr(%q) returned nil err, want an error", s)
+		}
+	}
+}
+
+func TestExpandTemplates(t *testing.T) {
+	input := "question,vars,expr,count\nWhat is {{a}} + {{b}}?,\"a=1..5|b=10,20\",a + b,10\nWhat is {{a * b}} / {{b}}?,a=1..9|b=0..3,a * b / b,3\n"
+	load := func(seed int64) []question {
+		questions, err := csvLoader{}.Load(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Load returned unexpected err: %s", err)
+		}
+		questions, err = expandTemplates(questions, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatalf("expandTemplates returned unexpected err: %s", err)
+		}
+		return questions
+	}
+
+	questions := load(1)
+	if len(questions) != 13 {
+		t.Fatalf("expandTemplates expanded %d questions, want 13", len(questions))
+	}
+	seen := map[string]bool{}
+	for _, q := range questions[:10] {
+		var a, b int
+		if _, err := fmt.Sscanf(q.question, "What is %d + %d?", &a, &b); err != nil {
+			t.Fatalf("expanded question %q doesn't match its template: %s", q.question, err)
+		}
+		if want := strconv.Itoa(a + b); !reflect.DeepEqual(q.answers, []string{want}) {
+			t.Errorf("expanded question %q has answers %q, want [%s]", q.question, q.answers, want)
+		}
+		if q.match.mode != matchNumeric {
+			t.Errorf("expanded question %q has match mode %q, want %q", q.question, q.match.mode, matchNumeric)
+		}
+		if seen[q.question] {
+			t.Errorf("question %q was expanded more than once", q.question)
+		}
+		seen[q.question] = true
+	}
+	for _, q := range questions[10:] {
+		if strings.HasSuffix(q.question, "/ 0?") {
+			t.Errorf("expanded question %q divides by zero", q.question)
+		}
+	}
+
+	if again := load(1); !reflect.DeepEqual(questions, again) {
+		t.Errorf("expandTemplates with the same seed expanded different questions")
+	}
+}
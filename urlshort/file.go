@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// extToDecoder maps the extension of a config file to the
+// function which decodes it.
+var extToDecoder = map[string]func([]byte) ([]pathURL, error){
+	".yaml": decodeYAML,
+	".yml":  decodeYAML,
+	".json": decodeJSON,
+	".toml": decodeTOML,
+}
+
+// configDecoder returns the function which decodes the config
+// file at path, based on its extension.
+func configDecoder(path string) (func([]byte) ([]pathURL, error), error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	decode, ok := extToDecoder[ext]
+	if !ok {
+		return nil, fmt.Errorf("unsupported config file extension %q, expected .yaml, .yml, .json or .toml", ext)
+	}
+	return decode, nil
+}
+
+// FileHandler is an http.Handler that redirects the paths
+// in a YAML, JSON or TOML config file, in the same format as
+// YAMLHandler, JSONHandler or TOMLHandler expects, to their
+// corresponding URL. If the path is not in the file, then the
+// fallback http.Handler will be called instead.
+//
+// The file can be reloaded while the handler is serving.
+// The paths are swapped atomically, so each request sees
+// either the old or the new paths and requests which are in
+// flight are never dropped. If the file can't be reloaded,
+// then the old paths are kept.
+type FileHandler struct {
+	path     string
+	decode   func([]byte) ([]pathURL, error)
+	fallback http.Handler
+	// handler holds the http.Handler for the current paths.
+	handler atomic.Value
+
+	// mu serialises reloads so that an older version of the
+	// file can't replace a newer one.
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+// NewFileHandler returns a FileHandler for the config file
+// at path, which has its format inferred from its
+// extension. An error is returned if the file can't be read
+// or parsed.
+func NewFileHandler(path string, fallback http.Handler) (*FileHandler, error) {
+	decode, err := configDecoder(path)
+	if err != nil {
+		return nil, err
+	}
+	h := &FileHandler{path: path, decode: decode, fallback: fallback}
+	if err := h.Reload(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *FileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.handler.Load().(http.Handler).ServeHTTP(w, r)
+}
+
+// Reload reads the config file and swaps in its paths.
+func (h *FileHandler) Reload() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return fmt.Errorf("stat config file: %w", err)
+	}
+	return h.reload(info.ModTime())
+}
+
+func (h *FileHandler) reload(modTime time.Time) error {
+	// The modification time is recorded even if the file is
+	// invalid so that it's only reported once by Watch.
+	h.modTime = modTime
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	config, err := h.decode(data)
+	if err != nil {
+		return fmt.Errorf("parse config file %s: %w", h.path, err)
+	}
+	pathsToRedirects, err := pathsToRedirects(config)
+	if err != nil {
+		return fmt.Errorf("parse config file %s: %w", h.path, err)
+	}
+	h.handler.Store(http.Handler(RedirectHandler(pathsToRedirects, h.fallback)))
+	return nil
+}
+
+// ReloadIfModified reloads the config file if its
+// modification time has changed since it was last loaded and
+// returns whether it was reloaded.
+func (h *FileHandler) ReloadIfModified() (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return false, fmt.Errorf("stat config file: %w", err)
+	}
+	if info.ModTime().Equal(h.modTime) {
+		return false, nil
+	}
+	if err := h.reload(info.ModTime()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Watch reloads the config file whenever its modification
+// time changes, checking every interval, and whenever the
+// process receives SIGHUP. Polling is disabled if interval is
+// 0. Errors are logged and the old paths are kept. Watch
+// blocks until stop is closed.
+func (h *FileHandler) Watch(interval time.Duration, stop <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hup:
+			if err := h.Reload(); err != nil {
+				log.Printf("Failed to reload %s on SIGHUP: %s", h.path, err)
+				continue
+			}
+			log.Printf("Reloaded %s on SIGHUP.", h.path)
+		case <-tick:
+			reloaded, err := h.ReloadIfModified()
+			if err != nil {
+				log.Printf("Failed to reload %s: %s", h.path, err)
+				continue
+			}
+			if reloaded {
+				log.Printf("Reloaded %s after it was modified.", h.path)
+			}
+		}
+	}
+}
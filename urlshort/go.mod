@@ -2,4 +2,7 @@ module github.com/marcuscaisey/gophercises/urlshort
 
 go 1.18
 
-require gopkg.in/yaml.v3 v3.0.0-20220512140231-539c8e751b99
+require (
+	github.com/BurntSushi/toml v1.3.2
+	gopkg.in/yaml.v3 v3.0.0-20220512140231-539c8e751b99
+)
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 
+	"github.com/BurntSushi/toml"
 	"gopkg.in/yaml.v3"
 )
 
@@ -13,20 +15,98 @@ import (
 // that each key in the map points to, in string format).
 // If the path is not provided in the map, then the fallback
 // http.Handler will be called instead.
+//
+// A path can also be a pattern which maps a whole family of
+// paths, like /issue/{id} which matches any single segment
+// after /issue/ or /gh/* which matches everything after
+// /gh/. The matched segments are substituted into the URL in
+// place of {id} or, for *, {rest}:
+//
+//     "/issue/{id}": "https://tracker/browse/PROJ-{id}",
+//     "/gh/*":       "https://github.com/{rest}",
+//
+// The most specific match wins. An exact path always wins
+// over a pattern and, comparing segment by segment, literal
+// text wins over a {param} which wins over a *. MapHandler
+// panics if a pattern is invalid.
+//
+// See RedirectHandler to control the status code, query
+// string and headers of each redirect.
 func MapHandler(pathsToURLs map[string]string, fallback http.Handler) http.HandlerFunc {
+	pathsToRedirects := make(map[string]Redirect, len(pathsToURLs))
+	for path, url := range pathsToURLs {
+		pathsToRedirects[path] = Redirect{URL: url}
+	}
+	return RedirectHandler(pathsToRedirects, fallback)
+}
+
+// RedirectHandler is the same as MapHandler, except that each
+// path maps to a Redirect which describes how to redirect
+// it. RedirectHandler also panics if a redirect has an
+// unsupported status code or query mode.
+func RedirectHandler(pathsToRedirects map[string]Redirect, fallback http.Handler) http.HandlerFunc {
+	if err := validateRedirects(pathsToRedirects); err != nil {
+		panic(err)
+	}
+	patterns, err := compilePatterns(pathsToRedirects)
+	if err != nil {
+		panic(err)
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		mappedURL, ok := pathsToURLs[r.URL.Path]
-		if ok {
-			http.Redirect(w, r, mappedURL, http.StatusFound)
+		if rd, ok := pathsToRedirects[r.URL.Path]; ok {
+			rd.serve(w, r, rd.URL)
 			return
 		}
+		for _, p := range patterns {
+			if target, ok := p.match(r.URL.EscapedPath()); ok {
+				p.redirect.serve(w, r, target)
+				return
+			}
+		}
 		fallback.ServeHTTP(w, r)
 	}
 }
 
-type yamlHandlerConfig []struct {
-	Path string `yaml:"path"`
-	URL  string `yaml:"url"`
+func validateRedirects(pathsToRedirects map[string]Redirect) error {
+	for path, rd := range pathsToRedirects {
+		if err := rd.validate(); err != nil {
+			return fmt.Errorf("redirect of %q: %w", path, err)
+		}
+	}
+	return nil
+}
+
+type pathURL struct {
+	Path    string            `yaml:"path" json:"path" toml:"path"`
+	URL     string            `yaml:"url" json:"url" toml:"url"`
+	Status  int               `yaml:"status" json:"status" toml:"status"`
+	Query   string            `yaml:"query" json:"query" toml:"query"`
+	Headers map[string]string `yaml:"headers" json:"headers" toml:"headers"`
+}
+
+func (c pathURL) redirect() Redirect {
+	return Redirect{URL: c.URL, Status: c.Status, Query: c.Query, Headers: c.Headers}
+}
+
+// pathsToRedirects returns the mapping of paths to redirects
+// in config. If a path is defined more than once, then the
+// first definition is used, as it is by LoadRedirects. An
+// error is returned if any of the paths are invalid patterns
+// or any of the redirects have unsupported options.
+func pathsToRedirects(config []pathURL) (map[string]Redirect, error) {
+	pathsToRedirects := make(map[string]Redirect, len(config))
+	for _, c := range config {
+		if _, ok := pathsToRedirects[c.Path]; !ok {
+			pathsToRedirects[c.Path] = c.redirect()
+		}
+	}
+	if err := validateRedirects(pathsToRedirects); err != nil {
+		return nil, err
+	}
+	if _, err := compilePatterns(pathsToRedirects); err != nil {
+		return nil, err
+	}
+	return pathsToRedirects, nil
 }
 
 // YAMLHandler will parse the provided YAML and then return
@@ -40,19 +120,107 @@ type yamlHandlerConfig []struct {
 //     - path: /some-path
 //       url: https://www.some-url.com/demo
 //
+// Each redirect can optionally set its status code, how the
+// request's query string is passed on and extra headers:
+//
+//     - path: /api/*
+//       url: https://api.some-url.com/v2/{rest}
+//       status: 308
+//       query: merge
+//       headers:
+//         Cache-Control: max-age=3600
+//
 // The only errors that can be returned all related to having
-// invalid YAML data.
+// invalid YAML data, invalid patterns or unsupported options.
 //
 // See MapHandler to create a similar http.HandlerFunc via
 // a mapping of paths to urls.
 func YAMLHandler(yml []byte, fallback http.Handler) (http.HandlerFunc, error) {
-	var config yamlHandlerConfig
+	pathsToRedirects, err := parseYAML(yml)
+	if err != nil {
+		return nil, err
+	}
+	return RedirectHandler(pathsToRedirects, fallback), nil
+}
+
+func parseYAML(yml []byte) (map[string]Redirect, error) {
+	config, err := decodeYAML(yml)
+	if err != nil {
+		return nil, err
+	}
+	return pathsToRedirects(config)
+}
+
+func decodeYAML(yml []byte) ([]pathURL, error) {
+	var config []pathURL
 	if err := yaml.Unmarshal(yml, &config); err != nil {
 		return nil, fmt.Errorf("unmarshal yaml: %w", err)
 	}
-	pathsToURLs := make(map[string]string, len(config))
-	for _, c := range config {
-		pathsToURLs[c.Path] = c.URL
+	return config, nil
+}
+
+// JSONHandler is the same as YAMLHandler, except that it
+// parses the provided JSON, which is expected to be in the
+// format:
+//
+//     [
+//       {"path": "/some-path", "url": "https://www.some-url.com/demo"}
+//     ]
+func JSONHandler(jsn []byte, fallback http.Handler) (http.HandlerFunc, error) {
+	pathsToRedirects, err := parseJSON(jsn)
+	if err != nil {
+		return nil, err
+	}
+	return RedirectHandler(pathsToRedirects, fallback), nil
+}
+
+func parseJSON(jsn []byte) (map[string]Redirect, error) {
+	config, err := decodeJSON(jsn)
+	if err != nil {
+		return nil, err
+	}
+	return pathsToRedirects(config)
+}
+
+func decodeJSON(jsn []byte) ([]pathURL, error) {
+	var config []pathURL
+	if err := json.Unmarshal(jsn, &config); err != nil {
+		return nil, fmt.Errorf("unmarshal json: %w", err)
+	}
+	return config, nil
+}
+
+type tomlHandlerConfig struct {
+	Redirects []pathURL `toml:"redirects"`
+}
+
+// TOMLHandler is the same as YAMLHandler, except that it
+// parses the provided TOML, which is expected to be in the
+// format:
+//
+//     [[redirects]]
+//     path = "/some-path"
+//     url = "https://www.some-url.com/demo"
+func TOMLHandler(tml []byte, fallback http.Handler) (http.HandlerFunc, error) {
+	pathsToRedirects, err := parseTOML(tml)
+	if err != nil {
+		return nil, err
+	}
+	return RedirectHandler(pathsToRedirects, fallback), nil
+}
+
+func parseTOML(tml []byte) (map[string]Redirect, error) {
+	config, err := decodeTOML(tml)
+	if err != nil {
+		return nil, err
+	}
+	return pathsToRedirects(config)
+}
+
+func decodeTOML(tml []byte) ([]pathURL, error) {
+	var config tomlHandlerConfig
+	if err := toml.Unmarshal(tml, &config); err != nil {
+		return nil, fmt.Errorf("unmarshal toml: %w", err)
 	}
-	return MapHandler(pathsToURLs, fallback), nil
+	return config.Redirects, nil
 }
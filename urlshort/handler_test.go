@@ -0,0 +1,222 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var notFound = http.NotFoundHandler()
+
+func redirectLocation(t *testing.T, h http.Handler, path string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w.Header().Get("Location")
+}
+
+func TestConfigHandlers(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handler func([]byte, http.Handler) (http.HandlerFunc, error)
+		config  string
+	}{
+		{
+			name:    "yaml",
+			handler: YAMLHandler,
+			config:  "- path: /go\n  url: https://go.dev\n",
+		},
+		{
+			name:    "json",
+			handler: JSONHandler,
+			config:  `[{"path": "/go", "url": "https://go.dev"}]`,
+		},
+		{
+			name:    "toml",
+			handler: TOMLHandler,
+			config:  "[[redirects]]\npath = \"/go\"\nurl = \"https://go.dev\"\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := tc.handler([]byte(tc.config), notFound)
+			if err != nil {
+				t.Fatalf("handler returned unexpected err: %s", err)
+			}
+			if got, want := redirectLocation(t, h, "/go"), "https://go.dev"; got != want {
+				t.Errorf("GET /go redirected to %q, want %q", got, want)
+			}
+			if got := redirectLocation(t, h, "/other"); got != "" {
+				t.Errorf("GET /other redirected to %q, want the fallback to be called", got)
+			}
+		})
+	}
+}
+
+func TestFileHandlerReloadsWhenModified(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redirects.json")
+	writeFile := func(content string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now()
+	writeFile(`[{"path": "/go", "url": "https://go.dev"}]`, start)
+
+	h, err := NewFileHandler(path, notFound)
+	if err != nil {
+		t.Fatalf("NewFileHandler returned unexpected err: %s", err)
+	}
+
+	writeFile(`[{"path": "/go", "url": "https://golang.org"}]`, start.Add(time.Second))
+	if reloaded, err := h.ReloadIfModified(); !reloaded || err != nil {
+		t.Fatalf("ReloadIfModified() = %t, %v, want true, nil", reloaded, err)
+	}
+	if got, want := redirectLocation(t, h, "/go"), "https://golang.org"; got != want {
+		t.Errorf("GET /go redirected to %q after reload, want %q", got, want)
+	}
+
+	writeFile(`not json`, start.Add(2*time.Second))
+	if _, err := h.ReloadIfModified(); err == nil {
+		t.Fatalf("ReloadIfModified() of invalid file returned nil err, want an error")
+	}
+	if got, want := redirectLocation(t, h, "/go"), "https://golang.org"; got != want {
+		t.Errorf("GET /go redirected to %q after failed reload, want %q", got, want)
+	}
+}
+
+func TestMapHandlerPatterns(t *testing.T) {
+	h := MapHandler(map[string]string{
+		"/gh":             "https://github.com",
+		"/gh/*":           "https://github.com/{rest}",
+		"/gh/{user}":      "https://github.com/{user}?tab=repositories",
+		"/gh/golang/*":    "https://github.com/golang/{rest}",
+		"/issue/{id}":     "https://tracker/browse/PROJ-{id}",
+		"/{team}/issue/*": "https://tracker/{team}/{rest}",
+	}, notFound)
+
+	testCases := []struct {
+		path string
+		want string
+	}{
+		{path: "/gh", want: "https://github.com"},
+		{path: "/gh/marcuscaisey", want: "https://github.com/marcuscaisey?tab=repositories"},
+		{path: "/gh/marcuscaisey/gophercises/tree/main", want: "https://github.com/marcuscaisey/gophercises/tree/main"},
+		{path: "/gh/golang/go", want: "https://github.com/golang/go"},
+		{path: "/gh/", want: "https://github.com/"},
+		{path: "/issue/123", want: "https://tracker/browse/PROJ-123"},
+		{path: "/issue/123/comments", want: ""},
+		{path: "/issue/", want: ""},
+		{path: "/infra/issue/1/2", want: "https://tracker/infra/1/2"},
+		{path: "/other", want: ""},
+	}
+
+	for _, tc := range testCases {
+		if got := redirectLocation(t, h, tc.path); got != tc.want {
+			t.Errorf("GET %s redirected to %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestParsePatternErrors(t *testing.T) {
+	testCases := []struct {
+		path string
+		url  string
+	}{
+		{path: "/gh/*/x", url: "https://github.com"},
+		{path: "/gh/a*", url: "https://github.com"},
+		{path: "/issue/{}", url: "https://tracker"},
+		{path: "/{id}/{id}", url: "https://tracker"},
+		{path: "/issue/{id}", url: "https://tracker/{key}"},
+		{path: "gh/*", url: "https://github.com"},
+	}
+
+	for _, tc := range testCases {
+		if _, err := parsePattern(tc.path, Redirect{URL: tc.url}); err == nil {
+			t.Errorf("parsePattern(%q, %q) returned nil err, want an error", tc.path, tc.url)
+		}
+	}
+}
+
+func TestRedirectOptions(t *testing.T) {
+	yml := `
+- path: /moved
+  url: https://example.com/new
+  status: 301
+  headers:
+    Cache-Control: max-age=3600
+- path: /api/*
+  url: https://api.example.com/v2/{rest}?key=default&format=json
+  status: 308
+  query: merge
+- path: /search
+  url: https://example.com/search?source=short
+  query: append
+`
+	h, err := YAMLHandler([]byte(yml), notFound)
+	if err != nil {
+		t.Fatalf("YAMLHandler returned unexpected err: %s", err)
+	}
+
+	testCases := []struct {
+		method       string
+		target       string
+		wantStatus   int
+		wantLocation string
+		wantHeaders  map[string]string
+	}{
+		{
+			method:       http.MethodGet,
+			target:       "/moved?dropped=1",
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "https://example.com/new",
+			wantHeaders:  map[string]string{"Cache-Control": "max-age=3600"},
+		},
+		{
+			method:       http.MethodPost,
+			target:       "/api/users?key=secret",
+			wantStatus:   http.StatusPermanentRedirect,
+			wantLocation: "https://api.example.com/v2/users?format=json&key=secret",
+		},
+		{
+			method:       http.MethodGet,
+			target:       "/search?q=go&q=urlshort",
+			wantStatus:   http.StatusFound,
+			wantLocation: "https://example.com/search?source=short&q=go&q=urlshort",
+		},
+	}
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, nil))
+		if w.Code != tc.wantStatus {
+			t.Errorf("%s %s returned status %d, want %d", tc.method, tc.target, w.Code, tc.wantStatus)
+		}
+		if got := w.Header().Get("Location"); got != tc.wantLocation {
+			t.Errorf("%s %s redirected to %q, want %q", tc.method, tc.target, got, tc.wantLocation)
+		}
+		for name, want := range tc.wantHeaders {
+			if got := w.Header().Get(name); got != want {
+				t.Errorf("%s %s returned %s header %q, want %q", tc.method, tc.target, name, got, want)
+			}
+		}
+	}
+}
+
+func TestYAMLHandlerRejectsUnsupportedOptions(t *testing.T) {
+	for _, yml := range []string{
+		"- path: /a\n  url: https://example.com\n  status: 303\n",
+		"- path: /a\n  url: https://example.com\n  query: replace\n",
+	} {
+		if _, err := YAMLHandler([]byte(yml), notFound); err == nil {
+			t.Errorf("YAMLHandler(%q) returned nil err, want an error", yml)
+		}
+	}
+}
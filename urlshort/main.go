@@ -1,33 +1,104 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 )
 
+var configFile = flag.String("config", "", "YAML, JSON or TOML file of redirects which is reloaded when it changes or on SIGHUP")
+var pollInterval = flag.Duration("poll", 2*time.Second, "how often to check whether the -config file has changed (0 to only reload on SIGHUP)")
+var check = flag.Bool("check", false, "validate the redirects and report any conflicts between their sources, then exit")
+
+// sourcePaths is the list of files and directories given by
+// the -source flag.
+type sourcePaths []string
+
+func (p *sourcePaths) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *sourcePaths) Set(path string) error {
+	*p = append(*p, path)
+	return nil
+}
+
+var sources sourcePaths
+
+func init() {
+	flag.Var(&sources, "source", "YAML, JSON or TOML file or directory of files of redirects, can be given more than once with earlier sources taking precedence")
+}
+
 func main() {
+	flag.Parse()
+
 	mux := defaultMux()
 
-	// Build the MapHandler using the mux as the fallback
 	pathsToUrls := map[string]string{
 		"/urlshort-godoc": "https://godoc.org/github.com/gophercises/urlshort",
 		"/yaml-godoc":     "https://godoc.org/gopkg.in/yaml.v2",
 	}
-	mapHandler := MapHandler(pathsToUrls, mux)
-
-	// Build the YAMLHandler using the mapHandler as the
-	// fallback
 	yaml := `
 - path: /urlshort
   url: https://github.com/gophercises/urlshort
 - path: /urlshort-final
   url: https://github.com/gophercises/urlshort/tree/solution`
-	yamlHandler, err := YAMLHandler([]byte(yaml), mapHandler)
+
+	// Build the handler from every source using the mux as the
+	// fallback. The -source files come first so that they can
+	// override the built-in YAML, which overrides the
+	// built-in map.
+	var redirectSources []Source
+	for _, path := range sources {
+		redirectSources = append(redirectSources, PathSource(path))
+	}
+	redirectSources = append(redirectSources, YAMLSource("built-in YAML", []byte(yaml)), MapSource("built-in map", pathsToUrls))
+
+	// The -config file is served on top of the other sources by
+	// a FileHandler, so it's only loaded as a source to check it
+	// and report its conflicts with the other sources.
+	checkSources := redirectSources
+	if *configFile != "" {
+		checkSources = append([]Source{FileSource(*configFile)}, redirectSources...)
+	}
+	_, conflicts, err := LoadRedirects(checkSources...)
+	if err != nil {
+		if *check {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		panic(err)
+	}
+	for _, conflict := range conflicts {
+		fmt.Println("Conflict:", conflict)
+	}
+	if *check {
+		fmt.Println("Config OK")
+		return
+	}
+
+	sourcesHandler, _, err := SourcesHandler(mux, redirectSources...)
 	if err != nil {
 		panic(err)
 	}
+
+	var handler http.Handler = sourcesHandler
+	if *configFile != "" {
+		// Build the FileHandler using the sourcesHandler as the
+		// fallback and keep it up to date with the file
+		fileHandler, err := NewFileHandler(*configFile, sourcesHandler)
+		if err != nil {
+			panic(err)
+		}
+		go fileHandler.Watch(*pollInterval, nil)
+		handler = fileHandler
+	}
+
 	fmt.Println("Starting the server on :8080")
-	http.ListenAndServe(":8080", yamlHandler)
+	http.ListenAndServe(":8080", handler)
 }
 
 func defaultMux() *http.ServeMux {
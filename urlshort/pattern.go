@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// restCapture is the name that the part of a path matched by
+// a trailing * is substituted into a URL with.
+const restCapture = "rest"
+
+// segmentKind is the kind of a segment of a pathPattern. The
+// kinds are ordered from least to most specific.
+type segmentKind int
+
+const (
+	// wildcardSegment matches the rest of the path, however
+	// many segments it has.
+	wildcardSegment segmentKind = iota
+	// paramSegment matches any single non-empty segment.
+	paramSegment
+	// literalSegment only matches a segment equal to it.
+	literalSegment
+)
+
+type patternSegment struct {
+	kind segmentKind
+	// value is the text of a literal segment or the name of a
+	// param segment.
+	value string
+}
+
+// pathPattern is a path which matches a family of paths. Each
+// segment of the path is either literal text, a {name} param
+// which matches any single segment or, as the last segment, a
+// * wildcard which matches the rest of the path. The segments
+// which a path matches are substituted into the redirect's
+// URL in place of {name} or, for the wildcard, {rest}.
+type pathPattern struct {
+	path     string
+	segments []patternSegment
+	redirect Redirect
+}
+
+// isPattern reports whether path is a pattern rather than an
+// exact path.
+func isPattern(path string) bool {
+	return strings.ContainsAny(path, "*{")
+}
+
+// parsePattern parses the pattern path which is redirected
+// according to rd.
+func parsePattern(path string, rd Redirect) (pathPattern, error) {
+	url := rd.URL
+	if !strings.HasPrefix(path, "/") {
+		return pathPattern{}, fmt.Errorf("pattern %q doesn't start with /", path)
+	}
+	p := pathPattern{path: path, redirect: rd}
+	captures := map[string]bool{}
+	parts := strings.Split(path[1:], "/")
+	for i, part := range parts {
+		switch {
+		case part == "*":
+			if i != len(parts)-1 {
+				return pathPattern{}, fmt.Errorf("pattern %q has a * which isn't its last segment", path)
+			}
+			p.segments = append(p.segments, patternSegment{kind: wildcardSegment})
+			captures[restCapture] = true
+		case strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}"):
+			name := part[1 : len(part)-1]
+			if name == "" || strings.ContainsAny(name, "{}*") {
+				return pathPattern{}, fmt.Errorf("pattern %q has invalid param %q", path, part)
+			}
+			if name == restCapture {
+				return pathPattern{}, fmt.Errorf("pattern %q has param {%s}, which is reserved for the * wildcard", path, restCapture)
+			}
+			if captures[name] {
+				return pathPattern{}, fmt.Errorf("pattern %q has param {%s} more than once", path, name)
+			}
+			p.segments = append(p.segments, patternSegment{kind: paramSegment, value: name})
+			captures[name] = true
+		case strings.ContainsAny(part, "*{}"):
+			return pathPattern{}, fmt.Errorf("pattern %q has segment %q which mixes text with a * or param", path, part)
+		default:
+			p.segments = append(p.segments, patternSegment{kind: literalSegment, value: part})
+		}
+	}
+
+	for _, name := range placeholders(url) {
+		if !captures[name] {
+			return pathPattern{}, fmt.Errorf("url %q of pattern %q has {%s}, which the pattern doesn't capture", url, path, name)
+		}
+	}
+	return p, nil
+}
+
+// placeholders returns the name of each {name} placeholder in
+// url.
+func placeholders(url string) []string {
+	var names []string
+	for {
+		start := strings.Index(url, "{")
+		if start == -1 {
+			return names
+		}
+		end := strings.Index(url[start:], "}")
+		if end == -1 {
+			return names
+		}
+		names = append(names, url[start+1:start+end])
+		url = url[start+end+1:]
+	}
+}
+
+// match reports whether the pattern matches path and, if it
+// does, returns the URL with the matched segments substituted
+// into it.
+func (p pathPattern) match(path string) (string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return "", false
+	}
+	parts := strings.Split(path[1:], "/")
+	var replacements []string
+	for i, segment := range p.segments {
+		if segment.kind == wildcardSegment {
+			replacements = append(replacements, "{"+restCapture+"}", strings.Join(parts[i:], "/"))
+			return strings.NewReplacer(replacements...).Replace(p.redirect.URL), true
+		}
+		if i >= len(parts) {
+			return "", false
+		}
+		switch segment.kind {
+		case paramSegment:
+			if parts[i] == "" {
+				return "", false
+			}
+			replacements = append(replacements, "{"+segment.value+"}", parts[i])
+		case literalSegment:
+			if parts[i] != segment.value {
+				return "", false
+			}
+		}
+	}
+	if len(parts) != len(p.segments) {
+		return "", false
+	}
+	return strings.NewReplacer(replacements...).Replace(p.redirect.URL), true
+}
+
+// moreSpecificThan reports whether p should be tried before
+// q. The first segment that differs in kind decides, with
+// literals being more specific than params and params more
+// specific than wildcards. Otherwise, the pattern with more
+// segments is more specific.
+func (p pathPattern) moreSpecificThan(q pathPattern) bool {
+	for i := 0; i < len(p.segments) && i < len(q.segments); i++ {
+		if p.segments[i].kind != q.segments[i].kind {
+			return p.segments[i].kind > q.segments[i].kind
+		}
+	}
+	if len(p.segments) != len(q.segments) {
+		return len(p.segments) > len(q.segments)
+	}
+	return p.path < q.path
+}
+
+// compilePatterns parses the patterns in pathsToRedirects
+// and returns them ordered from most to least specific. Exact
+// paths are skipped.
+func compilePatterns(pathsToRedirects map[string]Redirect) ([]pathPattern, error) {
+	var patterns []pathPattern
+	for path, rd := range pathsToRedirects {
+		if !isPattern(path) {
+			continue
+		}
+		p, err := parsePattern(path, rd)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		return patterns[i].moreSpecificThan(patterns[j])
+	})
+	return patterns, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// The ways that a Redirect can pass the query string of the
+// request on to the URL that it redirects to.
+const (
+	// QueryDrop throws the request's query string away.
+	QueryDrop = ""
+	// QueryAppend adds the request's query string to the end
+	// of the URL's.
+	QueryAppend = "append"
+	// QueryMerge combines the request's query parameters with
+	// the URL's, with the request's replacing any of the URL's
+	// which have the same name.
+	QueryMerge = "merge"
+)
+
+// Redirect describes where a path redirects to and how.
+type Redirect struct {
+	URL string
+	// Status is the status code of the redirect, which is one
+	// of 301, 302, 307 or 308. It defaults to 302 if it's 0.
+	Status int
+	// Query is how the request's query string is passed on to
+	// URL, which is one of QueryDrop, QueryAppend or
+	// QueryMerge.
+	Query string
+	// Headers are extra headers to send with the redirect,
+	// like Cache-Control.
+	Headers map[string]string
+}
+
+// validate returns an error if the status or query mode of
+// the redirect aren't supported.
+func (rd Redirect) validate() error {
+	switch rd.Status {
+	case 0, http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return fmt.Errorf("unsupported status %d, expected 301, 302, 307 or 308", rd.Status)
+	}
+	switch rd.Query {
+	case QueryDrop, QueryAppend, QueryMerge:
+	default:
+		return fmt.Errorf("unsupported query mode %q, expected %q or %q", rd.Query, QueryAppend, QueryMerge)
+	}
+	return nil
+}
+
+// serve redirects the request to target, which is the
+// redirect's URL with any pattern captures substituted into
+// it.
+func (rd Redirect) serve(w http.ResponseWriter, r *http.Request, target string) {
+	for name, value := range rd.Headers {
+		w.Header().Set(name, value)
+	}
+	status := rd.Status
+	if status == 0 {
+		status = http.StatusFound
+	}
+	http.Redirect(w, r, withQuery(target, r.URL.RawQuery, rd.Query), status)
+}
+
+// withQuery returns target with the request's query string
+// passed on to it according to mode.
+func withQuery(target string, query string, mode string) string {
+	if query == "" || mode == QueryDrop {
+		return target
+	}
+	base, fragment, hasFragment := strings.Cut(target, "#")
+	base, targetQuery, _ := strings.Cut(base, "?")
+
+	switch mode {
+	case QueryAppend:
+		if targetQuery != "" {
+			query = targetQuery + "&" + query
+		}
+	case QueryMerge:
+		merged, err := url.ParseQuery(targetQuery)
+		if err != nil {
+			// The target's own query can't be merged
+			// into, so the request's is appended to it.
+			return withQuery(target, query, QueryAppend)
+		}
+		requestValues, err := url.ParseQuery(query)
+		if err != nil {
+			return target
+		}
+		for name, values := range requestValues {
+			merged[name] = values
+		}
+		query = merged.Encode()
+	}
+
+	target = base + "?" + query
+	if hasFragment {
+		target += "#" + fragment
+	}
+	return target
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A Source is somewhere that redirects are loaded from, like
+// a config file or a map literal.
+type Source interface {
+	// Load returns the redirects in the source, in the order
+	// that they're defined in.
+	Load() ([]SourcedRedirect, error)
+}
+
+// SourcedRedirect is a redirect along with a description of
+// where it was loaded from, like the config file that it's in.
+type SourcedRedirect struct {
+	Path     string
+	Redirect Redirect
+	Source   string
+}
+
+type sourceFunc func() ([]SourcedRedirect, error)
+
+func (f sourceFunc) Load() ([]SourcedRedirect, error) {
+	return f()
+}
+
+func sourcedRedirects(config []pathURL, source string) []SourcedRedirect {
+	redirects := make([]SourcedRedirect, 0, len(config))
+	for _, c := range config {
+		redirects = append(redirects, SourcedRedirect{Path: c.Path, Redirect: c.redirect(), Source: source})
+	}
+	return redirects
+}
+
+// MapSource returns a Source of the redirects in pathsToURLs,
+// which are described by name in conflict reports. The
+// redirects are loaded in order of their paths.
+func MapSource(name string, pathsToURLs map[string]string) Source {
+	return sourceFunc(func() ([]SourcedRedirect, error) {
+		paths := make([]string, 0, len(pathsToURLs))
+		for path := range pathsToURLs {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		redirects := make([]SourcedRedirect, 0, len(paths))
+		for _, path := range paths {
+			redirects = append(redirects, SourcedRedirect{Path: path, Redirect: Redirect{URL: pathsToURLs[path]}, Source: name})
+		}
+		return redirects, nil
+	})
+}
+
+// YAMLSource returns a Source of the redirects in yml, in the
+// format that YAMLHandler expects, which are described by
+// name in conflict reports.
+func YAMLSource(name string, yml []byte) Source {
+	return dataSource(name, yml, decodeYAML)
+}
+
+// JSONSource is the same as YAMLSource, except that it
+// decodes JSON in the format that JSONHandler expects.
+func JSONSource(name string, jsn []byte) Source {
+	return dataSource(name, jsn, decodeJSON)
+}
+
+// TOMLSource is the same as YAMLSource, except that it
+// decodes TOML in the format that TOMLHandler expects.
+func TOMLSource(name string, tml []byte) Source {
+	return dataSource(name, tml, decodeTOML)
+}
+
+func dataSource(name string, data []byte, decode func([]byte) ([]pathURL, error)) Source {
+	return sourceFunc(func() ([]SourcedRedirect, error) {
+		config, err := decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return sourcedRedirects(config, name), nil
+	})
+}
+
+// FileSource returns a Source of the redirects in the YAML,
+// JSON or TOML config file at path, which has its format
+// inferred from its extension.
+func FileSource(path string) Source {
+	return sourceFunc(func() ([]SourcedRedirect, error) {
+		return loadFile(path)
+	})
+}
+
+func loadFile(path string) ([]SourcedRedirect, error) {
+	decode, err := configDecoder(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	config, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sourcedRedirects(config, path), nil
+}
+
+// DirSource returns a Source of the redirects in every YAML,
+// JSON and TOML config file in dir, in order of their names.
+// Files with other extensions are ignored.
+func DirSource(dir string) Source {
+	return sourceFunc(func() ([]SourcedRedirect, error) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("read config directory: %w", err)
+		}
+		var redirects []SourcedRedirect
+		for _, entry := range entries {
+			ext := strings.ToLower(filepath.Ext(entry.Name()))
+			if _, ok := extToDecoder[ext]; !ok || entry.IsDir() {
+				continue
+			}
+			fileRedirects, err := loadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			redirects = append(redirects, fileRedirects...)
+		}
+		return redirects, nil
+	})
+}
+
+// PathSource returns a DirSource if path is a directory and a
+// FileSource otherwise.
+func PathSource(path string) Source {
+	return sourceFunc(func() ([]SourcedRedirect, error) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("stat config path: %w", err)
+		}
+		if info.IsDir() {
+			return DirSource(path).Load()
+		}
+		return FileSource(path).Load()
+	})
+}
+
+// Conflict is a path which is defined more than once. Sources
+// lists where each definition came from, in order of
+// precedence, so the first one is used.
+type Conflict struct {
+	Path    string
+	Sources []string
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s is defined in %s; using %s", c.Path, strings.Join(c.Sources, ", "), c.Sources[0])
+}
+
+// LoadRedirects loads the redirects from each of sources and
+// merges them. The sources are given in order of precedence,
+// so if a path is defined more than once, then the first
+// definition wins, and each such path is returned as a
+// Conflict. An error is returned if a source can't be loaded
+// or any of the redirects are invalid.
+func LoadRedirects(sources ...Source) (map[string]Redirect, []Conflict, error) {
+	pathsToRedirects := map[string]Redirect{}
+	pathToConflict := map[string]*Conflict{}
+	var conflictPaths []string
+	pathToSource := map[string]string{}
+	for _, source := range sources {
+		redirects, err := source.Load()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, sr := range redirects {
+			if err := sr.Redirect.validate(); err != nil {
+				return nil, nil, fmt.Errorf("%s: redirect of %q: %w", sr.Source, sr.Path, err)
+			}
+			if isPattern(sr.Path) {
+				if _, err := parsePattern(sr.Path, sr.Redirect); err != nil {
+					return nil, nil, fmt.Errorf("%s: %w", sr.Source, err)
+				}
+			}
+
+			firstSource, defined := pathToSource[sr.Path]
+			if !defined {
+				pathsToRedirects[sr.Path] = sr.Redirect
+				pathToSource[sr.Path] = sr.Source
+				continue
+			}
+			conflict, ok := pathToConflict[sr.Path]
+			if !ok {
+				conflict = &Conflict{Path: sr.Path, Sources: []string{firstSource}}
+				pathToConflict[sr.Path] = conflict
+				conflictPaths = append(conflictPaths, sr.Path)
+			}
+			conflict.Sources = append(conflict.Sources, sr.Source)
+		}
+	}
+
+	conflicts := make([]Conflict, 0, len(conflictPaths))
+	for _, path := range conflictPaths {
+		conflicts = append(conflicts, *pathToConflict[path])
+	}
+	return pathsToRedirects, conflicts, nil
+}
+
+// SourcesHandler returns an http.HandlerFunc which redirects
+// the paths from each of sources, merged by LoadRedirects, and
+// calls the fallback http.Handler for any other path. The
+// conflicts between the sources are returned so that they can
+// be reported.
+func SourcesHandler(fallback http.Handler, sources ...Source) (http.HandlerFunc, []Conflict, error) {
+	pathsToRedirects, conflicts, err := LoadRedirects(sources...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return RedirectHandler(pathsToRedirects, fallback), conflicts, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadRedirectsPrecedenceAndConflicts(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.yaml":    "- path: /a\n  url: https://a.yaml\n- path: /shared\n  url: https://a.yaml\n",
+		"b.json":    `[{"path": "/shared", "url": "https://b.json"}, {"path": "/b", "url": "https://b.json"}]`,
+		"notes.txt": "ignored",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pathsToRedirects, conflicts, err := LoadRedirects(
+		DirSource(dir),
+		TOMLSource("toml", []byte("[[redirects]]\npath = \"/b\"\nurl = \"https://toml\"\n")),
+		MapSource("map", map[string]string{"/shared": "https://map", "/map": "https://map"}),
+	)
+	if err != nil {
+		t.Fatalf("LoadRedirects returned unexpected err: %s", err)
+	}
+
+	wantURLs := map[string]string{
+		"/a":      "https://a.yaml",
+		"/shared": "https://a.yaml",
+		"/b":      "https://b.json",
+		"/map":    "https://map",
+	}
+	gotURLs := map[string]string{}
+	for path, rd := range pathsToRedirects {
+		gotURLs[path] = rd.URL
+	}
+	if !reflect.DeepEqual(gotURLs, wantURLs) {
+		t.Errorf("LoadRedirects loaded %v, want %v", gotURLs, wantURLs)
+	}
+
+	wantConflicts := []Conflict{
+		{Path: "/shared", Sources: []string{filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.json"), "map"}},
+		{Path: "/b", Sources: []string{filepath.Join(dir, "b.json"), "toml"}},
+	}
+	if !reflect.DeepEqual(conflicts, wantConflicts) {
+		t.Errorf("LoadRedirects returned conflicts %v, want %v", conflicts, wantConflicts)
+	}
+}
+
+func TestLoadRedirectsReportsInvalidSource(t *testing.T) {
+	_, _, err := LoadRedirects(YAMLSource("bad", []byte("- path: /a\n  url: https://a\n  status: 200\n")))
+	if err == nil {
+		t.Fatalf("LoadRedirects returned nil err, want an error")
+	}
+}
+
+func TestDuplicatePathResolvesLikeLoadRedirects(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redirects.yaml")
+	config := "- path: /dup\n  url: https://first\n- path: /dup\n  url: https://second\n"
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	pathsToRedirects, conflicts, err := LoadRedirects(FileSource(path))
+	if err != nil {
+		t.Fatalf("LoadRedirects returned unexpected err: %s", err)
+	}
+	if got, want := pathsToRedirects["/dup"].URL, "https://first"; got != want {
+		t.Errorf("LoadRedirects loaded /dup as %q, want %q", got, want)
+	}
+	wantConflicts := []Conflict{{Path: "/dup", Sources: []string{path, path}}}
+	if !reflect.DeepEqual(conflicts, wantConflicts) {
+		t.Errorf("LoadRedirects returned conflicts %v, want %v", conflicts, wantConflicts)
+	}
+
+	yamlHandler, err := YAMLHandler([]byte(config), notFound)
+	if err != nil {
+		t.Fatalf("YAMLHandler returned unexpected err: %s", err)
+	}
+	fileHandler, err := NewFileHandler(path, notFound)
+	if err != nil {
+		t.Fatalf("NewFileHandler returned unexpected err: %s", err)
+	}
+	for name, h := range map[string]http.Handler{"YAMLHandler": yamlHandler, "FileHandler": fileHandler} {
+		if got, want := redirectLocation(t, h, "/dup"), "https://first"; got != want {
+			t.Errorf("%s redirected /dup to %q, want %q like LoadRedirects", name, got, want)
+		}
+	}
+}
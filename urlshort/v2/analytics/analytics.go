@@ -0,0 +1,213 @@
+package analytics
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/marcuscaisey/gophercises/urlshort/v2/model"
+)
+
+type ClickRepository interface {
+	CreateClicks(clicks []model.Click) error
+}
+
+// Recorder records clicks in the background, writing them to a ClickRepository in batches so that recording a click
+// never blocks. If clicks are recorded faster than they can be written, then the clicks which don't fit in the buffer
+// are dropped.
+type Recorder struct {
+	clickRepo     ClickRepository
+	ipSalt        string
+	batchSize     int
+	flushInterval time.Duration
+
+	// mu guards closed so that clicks can't be sent after the channel has been closed.
+	mu      sync.RWMutex
+	closed  bool
+	clicks  chan model.Click
+	flushes chan chan struct{}
+	dropped int64
+	done    chan struct{}
+}
+
+// NewRecorder returns a Recorder which writes clicks once batchSize have been recorded or flushInterval has passed since
+// the last write. Client IPs are hashed with ipSalt, which should be kept secret so that the hashes can't be reversed.
+// If ipSalt is empty, then a random salt is used instead, so a visitor is only counted once within each run.
+func NewRecorder(clickRepo ClickRepository, ipSalt string, batchSize int, flushInterval time.Duration) *Recorder {
+	if ipSalt == "" {
+		ipSalt = randomSalt()
+	}
+	r := &Recorder{
+		clickRepo:     clickRepo,
+		ipSalt:        ipSalt,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		clicks:        make(chan model.Click, batchSize*10),
+		flushes:       make(chan chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Record records a click on shortPath by the request r.
+func (r *Recorder) Record(shortPath string, req *http.Request) {
+	click := model.Click{
+		ShortPath:       shortPath,
+		Time:            time.Now().UTC(),
+		Referrer:        req.Referer(),
+		UserAgentFamily: UserAgentFamily(req.UserAgent()),
+		VisitorHash:     HashIP(clientIP(req), r.ipSalt),
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.clicks <- click:
+	default:
+		atomic.AddInt64(&r.dropped, 1)
+	}
+}
+
+// Flush writes the clicks which have been recorded but not written yet, waiting for them to be written.
+func (r *Recorder) Flush() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+	flushed := make(chan struct{})
+	r.flushes <- flushed
+	<-flushed
+}
+
+// Close writes any clicks which haven't been written yet and stops recording clicks.
+func (r *Recorder) Close() {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.clicks)
+	}
+	r.mu.Unlock()
+	<-r.done
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	var batch []model.Click
+	for {
+		select {
+		case click, ok := <-r.clicks:
+			if !ok {
+				r.flush(batch)
+				return
+			}
+			batch = append(batch, click)
+			if len(batch) >= r.batchSize {
+				r.flush(batch)
+				batch = nil
+			}
+		case flushed := <-r.flushes:
+			// The clicks channel can't be closed during a flush, since Flush holds mu until it's done.
+			for len(r.clicks) > 0 {
+				batch = append(batch, <-r.clicks)
+			}
+			r.flush(batch)
+			batch = nil
+			close(flushed)
+		case <-ticker.C:
+			r.flush(batch)
+			batch = nil
+		}
+	}
+}
+
+func (r *Recorder) flush(batch []model.Click) {
+	if dropped := atomic.SwapInt64(&r.dropped, 0); dropped > 0 {
+		log.Printf("Dropped %d clicks because the click buffer was full.", dropped)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	if err := r.clickRepo.CreateClicks(batch); err != nil {
+		log.Printf("Failed to record %d clicks: %s", len(batch), err)
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func randomSalt() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("generate random salt: %s", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// HashIP returns the hex encoded SHA-256 hash of ip salted with salt.
+func HashIP(ip, salt string) string {
+	sum := sha256.Sum256([]byte(salt + ip))
+	return hex.EncodeToString(sum[:])
+}
+
+// userAgentFamilies maps a token which appears in a user agent to its family. The tokens are checked in order since
+// user agents often contain the tokens of other browsers, like Chrome's which contains Safari.
+var userAgentFamilies = []struct {
+	token  string
+	family string
+}{
+	{"bot", "Bot"},
+	{"crawler", "Bot"},
+	{"spider", "Bot"},
+	{"curl/", "curl"},
+	{"wget/", "Wget"},
+	{"edg/", "Edge"},
+	{"edge/", "Edge"},
+	{"edga/", "Edge"},
+	{"edgios/", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"samsungbrowser/", "Samsung Internet"},
+	{"firefox/", "Firefox"},
+	{"fxios/", "Firefox"},
+	{"chrome/", "Chrome"},
+	{"crios/", "Chrome"},
+	{"chromium/", "Chrome"},
+	{"safari/", "Safari"},
+	{"msie ", "Internet Explorer"},
+	{"trident/", "Internet Explorer"},
+}
+
+// UserAgentFamily returns the family of the browser or client which sent the user agent ua, like Firefox or Chrome.
+func UserAgentFamily(ua string) string {
+	if ua == "" {
+		return "Unknown"
+	}
+	ua = strings.ToLower(ua)
+	for _, f := range userAgentFamilies {
+		if strings.Contains(ua, f.token) {
+			return f.family
+		}
+	}
+	return "Other"
+}
@@ -0,0 +1,96 @@
+package analytics
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/marcuscaisey/gophercises/urlshort/v2/model"
+)
+
+type fakeClickRepository struct {
+	mu      sync.Mutex
+	batches [][]model.Click
+}
+
+func (r *fakeClickRepository) CreateClicks(clicks []model.Click) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, clicks)
+	return nil
+}
+
+func TestRecorderBatchesClicks(t *testing.T) {
+	clickRepo := &fakeClickRepository{}
+	recorder := NewRecorder(clickRepo, "salt", 2, time.Hour)
+	for i := 0; i < 3; i++ {
+		r := httptest.NewRequest("GET", "/a", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header.Set("Referer", "https://example.com")
+		r.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:100.0) Gecko/20100101 Firefox/100.0")
+		recorder.Record("/a", r)
+	}
+	recorder.Close()
+
+	if len(clickRepo.batches) != 2 || len(clickRepo.batches[0]) != 2 || len(clickRepo.batches[1]) != 1 {
+		t.Fatalf("Recorder wrote batches %+v, want a batch of 2 clicks and then a batch of 1", clickRepo.batches)
+	}
+	click := clickRepo.batches[0][0]
+	if click.ShortPath != "/a" || click.Referrer != "https://example.com" || click.UserAgentFamily != "Firefox" || click.VisitorHash != HashIP("192.0.2.1", "salt") {
+		t.Errorf("Recorder recorded click %+v, which doesn't match its request", click)
+	}
+}
+
+func TestRecorderFlushWritesRecordedClicks(t *testing.T) {
+	clickRepo := &fakeClickRepository{}
+	recorder := NewRecorder(clickRepo, "salt", 10, time.Hour)
+	defer recorder.Close()
+	for i := 0; i < 3; i++ {
+		recorder.Record("/a", httptest.NewRequest("GET", "/a", nil))
+	}
+	recorder.Flush()
+
+	clickRepo.mu.Lock()
+	defer clickRepo.mu.Unlock()
+	if len(clickRepo.batches) != 1 || len(clickRepo.batches[0]) != 3 {
+		t.Fatalf("Recorder wrote batches %+v after Flush, want a batch of 3 clicks", clickRepo.batches)
+	}
+}
+
+func TestUserAgentFamily(t *testing.T) {
+	testCases := []struct {
+		ua   string
+		want string
+	}{
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/102.0.5005.63 Safari/537.36", "Chrome"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/102.0.5005.63 Safari/537.36 Edg/102.0.1245.33", "Edge"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 12_4) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.5 Safari/605.1.15", "Safari"},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "Bot"},
+		{"curl/7.83.1", "curl"},
+		{"", "Unknown"},
+		{"something", "Other"},
+	}
+
+	for _, tc := range testCases {
+		if got := UserAgentFamily(tc.ua); got != tc.want {
+			t.Errorf("UserAgentFamily(%q) = %q, want %q", tc.ua, got, tc.want)
+		}
+	}
+}
+
+func TestRecorderWithoutSaltUsesRandomSalt(t *testing.T) {
+	clickRepo := &fakeClickRepository{}
+	recorder := NewRecorder(clickRepo, "", 1, time.Hour)
+	r := httptest.NewRequest("GET", "/a", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	recorder.Record("/a", r)
+	recorder.Close()
+
+	if len(clickRepo.batches) != 1 {
+		t.Fatalf("Recorder wrote batches %+v, want 1 batch", clickRepo.batches)
+	}
+	if got := clickRepo.batches[0][0].VisitorHash; got == HashIP("192.0.2.1", "") {
+		t.Errorf("Recorder with an empty salt hashed the IP without a salt")
+	}
+}
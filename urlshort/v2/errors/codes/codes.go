@@ -9,6 +9,7 @@ const (
 	AlreadyExists
 	NotFound
 	BadRequest
+	Gone
 )
 
 var codeToStr = map[Code]string{
@@ -16,6 +17,7 @@ var codeToStr = map[Code]string{
 	AlreadyExists: "ALREADY_EXISTS",
 	NotFound:      "NOT_FOUND",
 	BadRequest:    "BAD_REQUEST",
+	Gone:          "GONE",
 }
 
 func (c Code) String() string {
@@ -0,0 +1,159 @@
+package generator
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/marcuscaisey/gophercises/urlshort/v2/model"
+)
+
+const base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+var base62 = big.NewInt(62)
+
+// Random generates codes of random base62 characters.
+type Random struct {
+	length int
+}
+
+func NewRandom(length int) *Random {
+	return &Random{length: length}
+}
+
+func (g *Random) Generate(longURL string, attempt int) string {
+	var b strings.Builder
+	for i := 0; i < g.length; i++ {
+		n, err := rand.Int(rand.Reader, base62)
+		if err != nil {
+			panic(err)
+		}
+		b.WriteByte(base62Alphabet[n.Int64()])
+	}
+	return b.String()
+}
+
+// Sequential generates codes by encoding a counter, which is incremented for each code, as base62. Codes are padded
+// with leading zeros to length and get longer once the counter no longer fits.
+type Sequential struct {
+	length int
+
+	mu   sync.Mutex
+	next uint64
+}
+
+func NewSequential(length int, start uint64) *Sequential {
+	return &Sequential{length: length, next: start}
+}
+
+// URLLister lists the URLs which have already been created.
+type URLLister interface {
+	List(prefix, after string, limit int) ([]model.URL, error)
+}
+
+// listPageSize is the number of URLs which are listed at a time by NewSequentialAfter.
+const listPageSize = 1000
+
+// NewSequentialAfter returns a Sequential generator whose counter starts after the largest counter that the short
+// paths in urlRepo could have been generated from, or from start if that's larger. This stops the generator from
+// generating codes which have already been taken when it's restarted.
+//
+// Only codes as long as the code of the counter are considered, moving on to longer codes once every code of a length
+// has been taken, so that custom short paths which happen to be longer base62 codes don't make the counter jump.
+func NewSequentialAfter(length int, start uint64, urlRepo URLLister) (*Sequential, error) {
+	g := NewSequential(length, start)
+	lengthToMaxCounter := map[int]uint64{}
+	var after string
+	for {
+		urls, err := urlRepo.List("/", after, listPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("list urls: %w", err)
+		}
+		for _, url := range urls {
+			code := strings.TrimPrefix(url.ShortPath, "/")
+			if n, ok := g.counter(code); ok && n >= lengthToMaxCounter[len(code)] {
+				lengthToMaxCounter[len(code)] = n
+			}
+		}
+		if len(urls) < listPageSize {
+			break
+		}
+		after = urls[len(urls)-1].ShortPath
+	}
+
+	for codeLength := len(g.code(g.next)); ; codeLength++ {
+		n, ok := lengthToMaxCounter[codeLength]
+		if !ok {
+			break
+		}
+		if n >= g.next {
+			g.next = n + 1
+		}
+		if len(g.code(g.next)) == codeLength {
+			break
+		}
+	}
+	return g, nil
+}
+
+// counter returns the value of the counter which code would have been generated from, if it could have been generated
+// by g.
+func (g *Sequential) counter(code string) (uint64, bool) {
+	if len(code) < g.length || (len(code) > g.length && code[0] == '0') {
+		return 0, false
+	}
+	n, ok := new(big.Int).SetString(code, 62)
+	if !ok || !n.IsUint64() || n.Uint64() == math.MaxUint64 {
+		return 0, false
+	}
+	return n.Uint64(), true
+}
+
+func (g *Sequential) code(n uint64) string {
+	code := new(big.Int).SetUint64(n).Text(62)
+	if len(code) < g.length {
+		code = strings.Repeat("0", g.length-len(code)) + code
+	}
+	return code
+}
+
+func (g *Sequential) Generate(longURL string, attempt int) string {
+	g.mu.Lock()
+	n := g.next
+	g.next++
+	g.mu.Unlock()
+
+	return g.code(n)
+}
+
+// MaxHashLength is the length of the base62 encoding of a SHA-256 hash, which is the longest code that Hash can
+// generate.
+const MaxHashLength = 43
+
+// Hash generates codes from the SHA-256 hash of the long URL, so the same URL always gets the same code. Each retry
+// after a collision hashes the URL with the attempt number to get a different code. Codes are at most
+// MaxHashLength characters long.
+type Hash struct {
+	length int
+}
+
+func NewHash(length int) *Hash {
+	return &Hash{length: length}
+}
+
+func (g *Hash) Generate(longURL string, attempt int) string {
+	input := longURL
+	if attempt > 0 {
+		input = fmt.Sprintf("%s#%d", longURL, attempt)
+	}
+	sum := sha256.Sum256([]byte(input))
+	code := new(big.Int).SetBytes(sum[:]).Text(62)
+	if len(code) > g.length {
+		code = code[:g.length]
+	}
+	return code
+}
@@ -0,0 +1,118 @@
+package generator
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcuscaisey/gophercises/urlshort/v2/errors"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/errors/codes"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/model"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/repo"
+)
+
+func isBase62(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune(base62Alphabet, c) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRandom(t *testing.T) {
+	g := NewRandom(10)
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		code := g.Generate("https://example.com", 0)
+		if len(code) != 10 || !isBase62(code) {
+			t.Fatalf("Generate() = %q, want 10 base62 characters", code)
+		}
+		if seen[code] {
+			t.Fatalf("Generate() returned %q twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestSequential(t *testing.T) {
+	g := NewSequential(3, 61)
+	for _, want := range []string{"00Z", "010", "011"} {
+		if got := g.Generate("https://example.com", 0); got != want {
+			t.Errorf("Generate() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	g := NewHash(8)
+	first := g.Generate("https://example.com", 0)
+	if len(first) != 8 || !isBase62(first) {
+		t.Fatalf("Generate() = %q, want 8 base62 characters", first)
+	}
+	if again := g.Generate("https://example.com", 0); again != first {
+		t.Errorf("Generate() of the same URL = %q then %q, want the same code", first, again)
+	}
+	if retry := g.Generate("https://example.com", 1); retry == first {
+		t.Errorf("Generate() of the same URL on a retry = %q, want a different code", retry)
+	}
+}
+
+func TestSequentialAfterRestart(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	urlRepo := repo.NewSQLiteURLRepository(db)
+	urlRepo.MustMigrate()
+	for _, shortPath := range []string{"/custom", "/ab", "/0zz"} {
+		if err := urlRepo.Create(model.URL{ShortPath: shortPath, LongURL: "https://example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g, err := NewSequentialAfter(3, 0, urlRepo)
+	if err != nil {
+		t.Fatalf("NewSequentialAfter returned unexpected err: %s", err)
+	}
+	var generated []string
+	for i := 0; i < 5; i++ {
+		code := g.Generate("https://example.com", 0)
+		if err := urlRepo.Create(model.URL{ShortPath: "/" + code, LongURL: "https://example.com"}); err != nil {
+			t.Fatalf("Create of generated code %q returned unexpected err: %s", code, err)
+		}
+		generated = append(generated, code)
+	}
+
+	// The generator is restarted with the same start against the populated repo.
+	restarted, err := NewSequentialAfter(3, 0, urlRepo)
+	if err != nil {
+		t.Fatalf("NewSequentialAfter returned unexpected err: %s", err)
+	}
+	code := restarted.Generate("https://example.com", 0)
+	if _, err := urlRepo.Get("/" + code); errors.Code(err) != codes.NotFound {
+		t.Errorf("Generate() after restart = %q, which was already taken by one of %q", code, generated)
+	}
+	if want := "0zF"; code != want {
+		t.Errorf("Generate() after restart = %q, want %q", code, want)
+	}
+}
+
+func TestSequentialAfterMovesOntoLongerCodes(t *testing.T) {
+	urlRepo := repo.NewInMemoryURLRepository()
+	// ZZ is the largest 2 character code, so the counter has moved onto 3 character codes.
+	for _, shortPath := range []string{"/ZZ", "/100", "/101"} {
+		if err := urlRepo.Create(model.URL{ShortPath: shortPath, LongURL: "https://example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g, err := NewSequentialAfter(2, 0, urlRepo)
+	if err != nil {
+		t.Fatalf("NewSequentialAfter returned unexpected err: %s", err)
+	}
+	if got, want := g.Generate("https://example.com", 0), "102"; got != want {
+		t.Errorf("Generate() = %q, want %q", got, want)
+	}
+}
@@ -5,33 +5,97 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/marcuscaisey/gophercises/urlshort/v2/analytics"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/generator"
 	"github.com/marcuscaisey/gophercises/urlshort/v2/repo"
 	"github.com/marcuscaisey/gophercises/urlshort/v2/server"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/sweeper"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/validator"
 )
 
 var sqliteFile = flag.String("db-file", "db.sqlite", "Path to SQLite DB")
 var inMemory = flag.Bool("in-memory", false, "Whether to use an in memory DB instead of SQLite")
 var port = flag.Uint("port", 8080, "Port to serve on")
+var generatorName = flag.String("generator", "random", "How to generate short paths which aren't given: random, sequential or hash")
+var length = flag.Int("length", 8, "Length of generated short paths")
+var sequenceStart = flag.Uint64("sequence-start", 0, "Value that the sequential generator's counter starts from")
+var ipSalt = flag.String("ip-salt", "", "Secret salt that client IPs are hashed with to count unique visitors. A random salt is used if it's not given.")
+var clickBatchSize = flag.Int("click-batch-size", 100, "Number of clicks to record in each batch")
+var clickFlushInterval = flag.Duration("click-flush-interval", 5*time.Second, "How often to record clicks which haven't filled a batch")
+var hosts = flag.String("hosts", "", "Comma separated hosts that the shortener is served on, which long URLs can't point to")
+var blocklistFile = flag.String("blocklist", "", "Path to a file of domains, one per line, which long URLs can't point to. Reloaded on SIGHUP.")
+var sweepInterval = flag.Duration("sweep-interval", time.Minute, "How often to delete expired and used up URLs, or 0 to never delete them")
 
 func main() {
 	flag.Parse()
 
-	var urlServer *server.Server
+	if *clickBatchSize < 1 {
+		panic(fmt.Sprintf("click-batch-size must be at least 1, got %d", *clickBatchSize))
+	}
+	if *clickFlushInterval <= 0 {
+		panic(fmt.Sprintf("click-flush-interval must be positive, got %s", *clickFlushInterval))
+	}
+	if *sweepInterval < 0 {
+		panic(fmt.Sprintf("sweep-interval must not be negative, got %s", *sweepInterval))
+	}
+
+	var urlRepo interface {
+		server.URLRepository
+		sweeper.URLRepository
+	}
+	var clickRepo interface {
+		server.ClickRepository
+		analytics.ClickRepository
+		sweeper.ClickRepository
+	}
 	if *inMemory {
 		log.Println("Using in-memory DB.")
-		repo := repo.NewInMemoryURLRepository()
-		urlServer = server.New(repo)
+		urlRepo = repo.NewInMemoryURLRepository()
+		clickRepo = repo.NewInMemoryClickRepository()
 
 	} else {
 		log.Printf("Using SQLite DB at %s.", *sqliteFile)
 		db := mustOpenSQLiteDB(*sqliteFile)
-		repo := repo.NewSQLiteURLRepository(db)
-		repo.MustMigrate()
-		urlServer = server.New(repo)
+		sqliteURLRepo := repo.NewSQLiteURLRepository(db)
+		sqliteURLRepo.MustMigrate()
+		sqliteClickRepo := repo.NewSQLiteClickRepository(db)
+		sqliteClickRepo.MustMigrate()
+		urlRepo = sqliteURLRepo
+		clickRepo = sqliteClickRepo
 	}
 
-	log.Fatal(urlServer.Run(*port))
+	shortPathGenerator := mustNewGenerator(*generatorName, *length, *sequenceStart, urlRepo)
+	if *ipSalt == "" {
+		log.Println("No -ip-salt given, so client IPs are hashed with a random salt and unique visitors are only counted within this run.")
+	}
+	clickRecorder := analytics.NewRecorder(clickRepo, *ipSalt, *clickBatchSize, *clickFlushInterval)
+	urlValidator := mustNewValidator(*hosts, *blocklistFile)
+	if *blocklistFile != "" {
+		go reloadBlocklistOnSIGHUP(urlValidator, *blocklistFile)
+	}
+	urlServer := server.New(urlRepo, clickRepo, clickRecorder, urlValidator, shortPathGenerator)
+
+	if *sweepInterval > 0 {
+		urlSweeper := sweeper.New(urlRepo, clickRepo, clickRecorder, *sweepInterval)
+		defer urlSweeper.Close()
+	}
+
+	go func() {
+		log.Fatal(urlServer.Run(*port))
+	}()
+
+	// Record the clicks which haven't been recorded yet before exiting.
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
+	<-shutdown
+	log.Println("Recording remaining clicks before exiting.")
+	clickRecorder.Close()
 }
 
 func mustOpenSQLiteDB(path string) *sql.DB {
@@ -41,3 +105,51 @@ func mustOpenSQLiteDB(path string) *sql.DB {
 	}
 	return db
 }
+
+func mustNewValidator(hosts string, blocklistPath string) *validator.Validator {
+	var selfHosts []string
+	for _, host := range strings.Split(hosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			selfHosts = append(selfHosts, host)
+		}
+	}
+	urlValidator, err := validator.New(selfHosts, blocklistPath)
+	if err != nil {
+		panic(fmt.Sprintf("create url validator: %s", err))
+	}
+	return urlValidator
+}
+
+func reloadBlocklistOnSIGHUP(urlValidator *validator.Validator, blocklistPath string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := urlValidator.Reload(); err != nil {
+			log.Printf("Failed to reload blocklist %s: %s", blocklistPath, err)
+			continue
+		}
+		log.Printf("Reloaded blocklist %s.", blocklistPath)
+	}
+}
+
+func mustNewGenerator(name string, length int, sequenceStart uint64, urlRepo generator.URLLister) server.Generator {
+	if length < 1 {
+		panic(fmt.Sprintf("length must be at least 1, got %d", length))
+	}
+	switch name {
+	case "random":
+		return generator.NewRandom(length)
+	case "sequential":
+		g, err := generator.NewSequentialAfter(length, sequenceStart, urlRepo)
+		if err != nil {
+			panic(fmt.Sprintf("create sequential generator: %s", err))
+		}
+		return g
+	case "hash":
+		if length > generator.MaxHashLength {
+			panic(fmt.Sprintf("length of hash generated short paths must be at most %d, got %d", generator.MaxHashLength, length))
+		}
+		return generator.NewHash(length)
+	}
+	panic(fmt.Sprintf("unknown generator %q, expected random, sequential or hash", name))
+}
@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+type URL struct {
+	ShortPath string `json:"short_path"`
+	LongURL   string `json:"long_url"`
+	// ExpiresAt is when the URL expires, or nil if it never expires.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// MaxClicks is the number of times that the URL can be visited before it's used up, or 0 if it can be visited any
+	// number of times.
+	MaxClicks int `json:"max_clicks,omitempty"`
+}
+
+type Click struct {
+	ShortPath       string
+	Time            time.Time
+	Referrer        string
+	UserAgentFamily string
+	// VisitorHash is a hash of the client's IP address, which identifies unique visitors without storing their IP.
+	VisitorHash string
+}
+
+type Stats struct {
+	TotalClicks    int               `json:"total_clicks"`
+	UniqueVisitors int               `json:"unique_visitors"`
+	Histogram      []HistogramBucket `json:"histogram"`
+	TopReferrers   []ReferrerClicks  `json:"top_referrers"`
+}
+
+type HistogramBucket struct {
+	Start  time.Time `json:"start"`
+	Clicks int       `json:"clicks"`
+}
+
+type ReferrerClicks struct {
+	Referrer string `json:"referrer"`
+	Clicks   int    `json:"clicks"`
+}
@@ -0,0 +1,82 @@
+package repo
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/marcuscaisey/gophercises/urlshort/v2/model"
+)
+
+type InMemoryClickRepository struct {
+	mu                sync.RWMutex
+	shortPathToClicks map[string][]model.Click
+}
+
+func NewInMemoryClickRepository() *InMemoryClickRepository {
+	return &InMemoryClickRepository{
+		shortPathToClicks: map[string][]model.Click{},
+	}
+}
+
+func (r *InMemoryClickRepository) CreateClicks(clicks []model.Click) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, click := range clicks {
+		r.shortPathToClicks[click.ShortPath] = append(r.shortPathToClicks[click.ShortPath], click)
+	}
+	return nil
+}
+
+func (r *InMemoryClickRepository) DeleteClicks(shortPaths []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, shortPath := range shortPaths {
+		delete(r.shortPathToClicks, shortPath)
+	}
+	return nil
+}
+
+func (r *InMemoryClickRepository) GetStats(shortPath string, bucketSize time.Duration, topReferrers int) (model.Stats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	clicks := r.shortPathToClicks[shortPath]
+
+	visitors := map[string]bool{}
+	bucketToClicks := map[int64]int{}
+	referrerToClicks := map[string]int{}
+	bucketSeconds := int64(bucketSize / time.Second)
+	for _, click := range clicks {
+		visitors[click.VisitorHash] = true
+		bucketToClicks[click.Time.Unix()/bucketSeconds*bucketSeconds]++
+		if click.Referrer != "" {
+			referrerToClicks[click.Referrer]++
+		}
+	}
+
+	stats := model.Stats{
+		TotalClicks:    len(clicks),
+		UniqueVisitors: len(visitors),
+		Histogram:      make([]model.HistogramBucket, 0, len(bucketToClicks)),
+		TopReferrers:   make([]model.ReferrerClicks, 0, len(referrerToClicks)),
+	}
+	for bucket, clicks := range bucketToClicks {
+		stats.Histogram = append(stats.Histogram, model.HistogramBucket{Start: time.Unix(bucket, 0).UTC(), Clicks: clicks})
+	}
+	sort.Slice(stats.Histogram, func(i, j int) bool {
+		return stats.Histogram[i].Start.Before(stats.Histogram[j].Start)
+	})
+	for referrer, clicks := range referrerToClicks {
+		stats.TopReferrers = append(stats.TopReferrers, model.ReferrerClicks{Referrer: referrer, Clicks: clicks})
+	}
+	sort.Slice(stats.TopReferrers, func(i, j int) bool {
+		if stats.TopReferrers[i].Clicks != stats.TopReferrers[j].Clicks {
+			return stats.TopReferrers[i].Clicks > stats.TopReferrers[j].Clicks
+		}
+		return stats.TopReferrers[i].Referrer < stats.TopReferrers[j].Referrer
+	})
+	if len(stats.TopReferrers) > topReferrers {
+		stats.TopReferrers = stats.TopReferrers[:topReferrers]
+	}
+	return stats, nil
+}
@@ -0,0 +1,164 @@
+package repo
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/marcuscaisey/gophercises/urlshort/v2/model"
+)
+
+// maxClicksPerInsert is the number of clicks which are inserted by each statement, which keeps the number of
+// parameters below SQLite's limit.
+const maxClicksPerInsert = 100
+
+// maxShortPathsPerDelete is the number of short paths which have their clicks deleted by each statement, which keeps
+// the number of parameters below SQLite's limit.
+const maxShortPathsPerDelete = 500
+
+type SQLiteClickRepository struct {
+	db DB
+}
+
+func NewSQLiteClickRepository(db DB) *SQLiteClickRepository {
+	return &SQLiteClickRepository{db: db}
+}
+
+func (r *SQLiteClickRepository) Migrate() error {
+	const createClicksTableQuery = `
+		CREATE TABLE IF NOT EXISTS clicks (
+			short_path TEXT NOT NULL,
+			time INTEGER NOT NULL,
+			referrer TEXT NOT NULL,
+			user_agent_family TEXT NOT NULL,
+			visitor_hash TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS clicks_short_path_time ON clicks (short_path, time);
+	`
+	log.Println("Ensuring that clicks table exists.")
+
+	_, err := r.db.Exec(createClicksTableQuery)
+	if err != nil {
+		return fmt.Errorf("create clicks table: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteClickRepository) MustMigrate() {
+	if err := r.Migrate(); err != nil {
+		panic(fmt.Sprintf("migrate: %s", err))
+	}
+}
+
+func (r *SQLiteClickRepository) CreateClicks(clicks []model.Click) error {
+	for len(clicks) > 0 {
+		n := len(clicks)
+		if n > maxClicksPerInsert {
+			n = maxClicksPerInsert
+		}
+		if err := r.insertClicks(clicks[:n]); err != nil {
+			return err
+		}
+		clicks = clicks[n:]
+	}
+	return nil
+}
+
+func (r *SQLiteClickRepository) insertClicks(clicks []model.Click) error {
+	const columns = 5
+	values := make([]string, 0, len(clicks))
+	args := make([]any, 0, len(clicks)*columns)
+	for i, click := range clicks {
+		n := i * columns
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5))
+		args = append(args, click.ShortPath, click.Time.Unix(), click.Referrer, click.UserAgentFamily, click.VisitorHash)
+	}
+	insertClicksQuery := "INSERT INTO clicks (short_path, time, referrer, user_agent_family, visitor_hash) VALUES " +
+		strings.Join(values, ", ") + ";"
+	if _, err := r.db.Exec(insertClicksQuery, args...); err != nil {
+		return fmt.Errorf("insert %d clicks: %w", len(clicks), err)
+	}
+	return nil
+}
+
+func (r *SQLiteClickRepository) DeleteClicks(shortPaths []string) error {
+	for len(shortPaths) > 0 {
+		n := len(shortPaths)
+		if n > maxShortPathsPerDelete {
+			n = maxShortPathsPerDelete
+		}
+		params := make([]string, 0, n)
+		args := make([]any, 0, n)
+		for i, shortPath := range shortPaths[:n] {
+			params = append(params, fmt.Sprintf("$%d", i+1))
+			args = append(args, shortPath)
+		}
+		deleteClicksQuery := "DELETE FROM clicks WHERE short_path IN (" + strings.Join(params, ", ") + ");"
+		if _, err := r.db.Exec(deleteClicksQuery, args...); err != nil {
+			return fmt.Errorf("delete clicks of %d short paths: %w", n, err)
+		}
+		shortPaths = shortPaths[n:]
+	}
+	return nil
+}
+
+func (r *SQLiteClickRepository) GetStats(shortPath string, bucketSize time.Duration, topReferrers int) (model.Stats, error) {
+	var stats model.Stats
+
+	const selectTotalsQuery = "SELECT COUNT(*), COUNT(DISTINCT visitor_hash) FROM clicks WHERE short_path = $1;"
+	if err := r.db.QueryRow(selectTotalsQuery, shortPath).Scan(&stats.TotalClicks, &stats.UniqueVisitors); err != nil {
+		return model.Stats{}, fmt.Errorf("select click totals of short_path = %q: %w", shortPath, err)
+	}
+
+	const selectHistogramQuery = `
+		SELECT time / $1 * $1 AS bucket, COUNT(*) FROM clicks
+		WHERE short_path = $2
+		GROUP BY bucket
+		ORDER BY bucket;
+	`
+	rows, err := r.db.Query(selectHistogramQuery, int64(bucketSize/time.Second), shortPath)
+	if err != nil {
+		return model.Stats{}, fmt.Errorf("select click histogram of short_path = %q: %w", shortPath, err)
+	}
+	defer rows.Close()
+	stats.Histogram = []model.HistogramBucket{}
+	for rows.Next() {
+		var start int64
+		var bucket model.HistogramBucket
+		if err := rows.Scan(&start, &bucket.Clicks); err != nil {
+			return model.Stats{}, fmt.Errorf("scan histogram bucket: %w", err)
+		}
+		bucket.Start = time.Unix(start, 0).UTC()
+		stats.Histogram = append(stats.Histogram, bucket)
+	}
+	if err := rows.Err(); err != nil {
+		return model.Stats{}, fmt.Errorf("iterate over histogram buckets: %w", err)
+	}
+
+	const selectTopReferrersQuery = `
+		SELECT referrer, COUNT(*) AS clicks FROM clicks
+		WHERE short_path = $1 AND referrer != ''
+		GROUP BY referrer
+		ORDER BY clicks DESC, referrer
+		LIMIT $2;
+	`
+	rows, err = r.db.Query(selectTopReferrersQuery, shortPath, topReferrers)
+	if err != nil {
+		return model.Stats{}, fmt.Errorf("select top referrers of short_path = %q: %w", shortPath, err)
+	}
+	defer rows.Close()
+	stats.TopReferrers = []model.ReferrerClicks{}
+	for rows.Next() {
+		var referrer model.ReferrerClicks
+		if err := rows.Scan(&referrer.Referrer, &referrer.Clicks); err != nil {
+			return model.Stats{}, fmt.Errorf("scan referrer: %w", err)
+		}
+		stats.TopReferrers = append(stats.TopReferrers, referrer)
+	}
+	if err := rows.Err(); err != nil {
+		return model.Stats{}, fmt.Errorf("iterate over referrers: %w", err)
+	}
+
+	return stats, nil
+}
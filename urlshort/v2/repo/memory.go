@@ -1,33 +1,123 @@
 package repo
 
 import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/marcuscaisey/gophercises/urlshort/v2/errors"
 	"github.com/marcuscaisey/gophercises/urlshort/v2/errors/codes"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/model"
 )
 
 type InMemoryURLRepository struct {
-	shortPathToLongURL map[string]string
+	mu             sync.RWMutex
+	shortPathToURL map[string]*inMemoryURL
+}
+
+type inMemoryURL struct {
+	model.URL
+	clicks int
 }
 
-func NewInMemoryURLRepository() InMemoryURLRepository {
-	return InMemoryURLRepository{
-		shortPathToLongURL: map[string]string{},
+// isExpired reports whether the URL has passed its expiry time or has been used up.
+func (u *inMemoryURL) isExpired(now time.Time) bool {
+	return (u.ExpiresAt != nil && !u.ExpiresAt.After(now)) || (u.MaxClicks > 0 && u.clicks >= u.MaxClicks)
+}
+
+func NewInMemoryURLRepository() *InMemoryURLRepository {
+	return &InMemoryURLRepository{
+		shortPathToURL: map[string]*inMemoryURL{},
 	}
 }
 
-func (r InMemoryURLRepository) Create(shortPath, longURL string) error {
-	_, found := r.shortPathToLongURL[shortPath]
+func (r *InMemoryURLRepository) Create(url model.URL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, found := r.shortPathToURL[url.ShortPath]
 	if found {
 		return errors.New(codes.AlreadyExists)
 	}
-	r.shortPathToLongURL[shortPath] = longURL
+	r.shortPathToURL[url.ShortPath] = &inMemoryURL{URL: url}
 	return nil
 }
 
-func (r InMemoryURLRepository) Get(shortPath string) (string, error) {
-	longURL, found := r.shortPathToLongURL[shortPath]
+func (r *InMemoryURLRepository) Get(shortPath string) (model.URL, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	url, found := r.shortPathToURL[shortPath]
+	if !found {
+		return model.URL{}, errors.New(codes.NotFound)
+	}
+	return url.URL, nil
+}
+
+func (r *InMemoryURLRepository) Visit(shortPath string, now time.Time) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	url, found := r.shortPathToURL[shortPath]
 	if !found {
 		return "", errors.New(codes.NotFound)
 	}
-	return longURL, nil
+	if url.isExpired(now) {
+		return "", errors.New(codes.Gone)
+	}
+	url.clicks++
+	return url.LongURL, nil
+}
+
+func (r *InMemoryURLRepository) Update(shortPath, longURL string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	url, found := r.shortPathToURL[shortPath]
+	if !found {
+		return errors.New(codes.NotFound)
+	}
+	url.LongURL = longURL
+	return nil
+}
+
+func (r *InMemoryURLRepository) Delete(shortPath string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, found := r.shortPathToURL[shortPath]; !found {
+		return errors.New(codes.NotFound)
+	}
+	delete(r.shortPathToURL, shortPath)
+	return nil
+}
+
+func (r *InMemoryURLRepository) DeleteExpired(now time.Time) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var shortPaths []string
+	for shortPath, url := range r.shortPathToURL {
+		if url.isExpired(now) {
+			delete(r.shortPathToURL, shortPath)
+			shortPaths = append(shortPaths, shortPath)
+		}
+	}
+	sort.Strings(shortPaths)
+	return shortPaths, nil
+}
+
+func (r *InMemoryURLRepository) List(prefix, after string, limit int) ([]model.URL, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var shortPaths []string
+	for shortPath := range r.shortPathToURL {
+		if shortPath > after && strings.HasPrefix(shortPath, prefix) {
+			shortPaths = append(shortPaths, shortPath)
+		}
+	}
+	sort.Strings(shortPaths)
+	if len(shortPaths) > limit {
+		shortPaths = shortPaths[:limit]
+	}
+	urls := make([]model.URL, 0, len(shortPaths))
+	for _, shortPath := range shortPaths {
+		urls = append(urls, r.shortPathToURL[shortPath].URL)
+	}
+	return urls, nil
 }
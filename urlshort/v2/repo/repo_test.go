@@ -0,0 +1,244 @@
+package repo
+
+import (
+	"database/sql"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/marcuscaisey/gophercises/urlshort/v2/errors"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/errors/codes"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/model"
+)
+
+type urlRepository interface {
+	Create(url model.URL) error
+	Get(shortPath string) (model.URL, error)
+	Visit(shortPath string, now time.Time) (string, error)
+	Update(shortPath, longURL string) error
+	Delete(shortPath string) error
+	List(prefix, after string, limit int) ([]model.URL, error)
+	DeleteExpired(now time.Time) ([]string, error)
+}
+
+func newRepos(t *testing.T) map[string]urlRepository {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	// Each connection to :memory: opens a different database.
+	db.SetMaxOpenConns(1)
+	sqliteRepo := NewSQLiteURLRepository(db)
+	sqliteRepo.MustMigrate()
+	return map[string]urlRepository{
+		"in memory": NewInMemoryURLRepository(),
+		"sqlite":    sqliteRepo,
+	}
+}
+
+func TestUpdateAndDelete(t *testing.T) {
+	for name, urlRepo := range newRepos(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := urlRepo.Create(model.URL{ShortPath: "/a", LongURL: "https://example.com/a"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := urlRepo.Update("/a", "https://example.com/b"); err != nil {
+				t.Fatalf("Update returned unexpected err: %s", err)
+			}
+			if got, err := urlRepo.Get("/a"); err != nil || got.LongURL != "https://example.com/b" {
+				t.Errorf(`Get("/a") = %+v, %v, want long URL "https://example.com/b"`, got, err)
+			}
+			if err := urlRepo.Update("/missing", "https://example.com"); errors.Code(err) != codes.NotFound {
+				t.Errorf("Update of missing short path returned err with code %s, want %s", errors.Code(err), codes.NotFound)
+			}
+
+			if err := urlRepo.Delete("/a"); err != nil {
+				t.Fatalf("Delete returned unexpected err: %s", err)
+			}
+			if _, err := urlRepo.Get("/a"); errors.Code(err) != codes.NotFound {
+				t.Errorf("Get of deleted short path returned err with code %s, want %s", errors.Code(err), codes.NotFound)
+			}
+			if err := urlRepo.Delete("/a"); errors.Code(err) != codes.NotFound {
+				t.Errorf("Delete of missing short path returned err with code %s, want %s", errors.Code(err), codes.NotFound)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	testCases := []struct {
+		name          string
+		prefix, after string
+		limit         int
+		want          []string
+	}{
+		{name: "all", limit: 10, want: []string{"/a", "/ab", "/b", "/ba", "/c"}},
+		{name: "limit", limit: 2, want: []string{"/a", "/ab"}},
+		{name: "after", after: "/ab", limit: 2, want: []string{"/b", "/ba"}},
+		{name: "prefix", prefix: "/b", limit: 10, want: []string{"/b", "/ba"}},
+		{name: "prefix and after", prefix: "/a", after: "/a", limit: 10, want: []string{"/ab"}},
+		{name: "none", prefix: "/d", limit: 10, want: nil},
+	}
+
+	for name, urlRepo := range newRepos(t) {
+		for _, shortPath := range []string{"/c", "/ba", "/a", "/b", "/ab"} {
+			if err := urlRepo.Create(model.URL{ShortPath: shortPath, LongURL: "https://example.com" + shortPath}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, tc := range testCases {
+			t.Run(name+"/"+tc.name, func(t *testing.T) {
+				urls, err := urlRepo.List(tc.prefix, tc.after, tc.limit)
+				if err != nil {
+					t.Fatalf("List returned unexpected err: %s", err)
+				}
+				var got []string
+				for _, url := range urls {
+					if url.LongURL != "https://example.com"+url.ShortPath {
+						t.Errorf("List returned %+v, want long URL https://example.com%s", url, url.ShortPath)
+					}
+					got = append(got, url.ShortPath)
+				}
+				if !reflect.DeepEqual(got, tc.want) {
+					t.Errorf("List(%q, %q, %d) returned short paths %q, want %q", tc.prefix, tc.after, tc.limit, got, tc.want)
+				}
+			})
+		}
+	}
+}
+
+func TestVisitAndDeleteExpired(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	future := now.Add(time.Hour)
+	past := now.Add(-time.Hour)
+	urls := []model.URL{
+		{ShortPath: "/forever", LongURL: "https://example.com/forever"},
+		{ShortPath: "/future", LongURL: "https://example.com/future", ExpiresAt: &future},
+		{ShortPath: "/past", LongURL: "https://example.com/past", ExpiresAt: &past},
+		{ShortPath: "/twice", LongURL: "https://example.com/twice", MaxClicks: 2},
+	}
+	testCases := []struct {
+		shortPath string
+		wantCode  codes.Code
+	}{
+		{shortPath: "/forever"},
+		{shortPath: "/future"},
+		{shortPath: "/past", wantCode: codes.Gone},
+		{shortPath: "/twice"},
+		{shortPath: "/twice"},
+		{shortPath: "/twice", wantCode: codes.Gone},
+		{shortPath: "/missing", wantCode: codes.NotFound},
+	}
+
+	for name, urlRepo := range newRepos(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, url := range urls {
+				if err := urlRepo.Create(url); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got, err := urlRepo.Get("/future"); err != nil || !reflect.DeepEqual(got, urls[1]) {
+				t.Errorf(`Get("/future") = %+v, %v, want %+v, nil`, got, err, urls[1])
+			}
+
+			for _, tc := range testCases {
+				longURL, err := urlRepo.Visit(tc.shortPath, now)
+				if code := errors.Code(err); code != tc.wantCode {
+					t.Errorf("Visit(%q) returned err %v with code %s, want code %s", tc.shortPath, err, code, tc.wantCode)
+				} else if err == nil && longURL != "https://example.com"+tc.shortPath {
+					t.Errorf("Visit(%q) = %q, want %q", tc.shortPath, longURL, "https://example.com"+tc.shortPath)
+				}
+			}
+
+			deleted, err := urlRepo.DeleteExpired(now)
+			if err != nil {
+				t.Fatalf("DeleteExpired returned unexpected err: %s", err)
+			}
+			sort.Strings(deleted)
+			if want := []string{"/past", "/twice"}; !reflect.DeepEqual(deleted, want) {
+				t.Errorf("DeleteExpired deleted %q, want %q", deleted, want)
+			}
+			remaining, err := urlRepo.List("", "", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(remaining) != 2 || remaining[0].ShortPath != "/forever" || remaining[1].ShortPath != "/future" {
+				t.Errorf("List after DeleteExpired returned %+v, want /forever and /future", remaining)
+			}
+		})
+	}
+}
+
+type clickRepository interface {
+	CreateClicks(clicks []model.Click) error
+	GetStats(shortPath string, bucketSize time.Duration, topReferrers int) (model.Stats, error)
+	DeleteClicks(shortPaths []string) error
+}
+
+func newClickRepos(t *testing.T) map[string]clickRepository {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+	sqliteRepo := NewSQLiteClickRepository(db)
+	sqliteRepo.MustMigrate()
+	return map[string]clickRepository{
+		"in memory": NewInMemoryClickRepository(),
+		"sqlite":    sqliteRepo,
+	}
+}
+
+func TestGetStats(t *testing.T) {
+	start := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	clicks := []model.Click{
+		{ShortPath: "/a", Time: start, Referrer: "https://x.com", VisitorHash: "1"},
+		{ShortPath: "/a", Time: start.Add(30 * time.Minute), Referrer: "https://y.com", VisitorHash: "2"},
+		{ShortPath: "/a", Time: start.Add(90 * time.Minute), Referrer: "https://y.com", VisitorHash: "1"},
+		{ShortPath: "/a", Time: start.Add(3 * time.Hour), VisitorHash: "3"},
+		{ShortPath: "/a", Time: start.Add(3 * time.Hour), Referrer: "https://z.com", VisitorHash: "3"},
+		{ShortPath: "/b", Time: start, Referrer: "https://x.com", VisitorHash: "4"},
+	}
+	want := model.Stats{
+		TotalClicks:    5,
+		UniqueVisitors: 3,
+		Histogram: []model.HistogramBucket{
+			{Start: start, Clicks: 2},
+			{Start: start.Add(time.Hour), Clicks: 1},
+			{Start: start.Add(3 * time.Hour), Clicks: 2},
+		},
+		TopReferrers: []model.ReferrerClicks{
+			{Referrer: "https://y.com", Clicks: 2},
+			{Referrer: "https://x.com", Clicks: 1},
+		},
+	}
+
+	for name, clickRepo := range newClickRepos(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := clickRepo.CreateClicks(clicks); err != nil {
+				t.Fatalf("CreateClicks returned unexpected err: %s", err)
+			}
+			got, err := clickRepo.GetStats("/a", time.Hour, 2)
+			if err != nil {
+				t.Fatalf("GetStats returned unexpected err: %s", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("GetStats returned %+v, want %+v", got, want)
+			}
+
+			if err := clickRepo.DeleteClicks([]string{"/a"}); err != nil {
+				t.Fatalf("DeleteClicks returned unexpected err: %s", err)
+			}
+			if got, err := clickRepo.GetStats("/a", time.Hour, 2); err != nil || got.TotalClicks != 0 {
+				t.Errorf("GetStats after DeleteClicks = %+v, %v, want no clicks", got, err)
+			}
+			if got, err := clickRepo.GetStats("/b", time.Hour, 2); err != nil || got.TotalClicks != 1 {
+				t.Errorf("GetStats of other short path after DeleteClicks = %+v, %v, want 1 click", got, err)
+			}
+		})
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/marcuscaisey/gophercises/urlshort/v2/errors"
 	"github.com/marcuscaisey/gophercises/urlshort/v2/errors/codes"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/model"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -16,6 +18,7 @@ type SQLiteURLRepository struct {
 
 type DB interface {
 	Exec(string, ...any) (sql.Result, error)
+	Query(string, ...any) (*sql.Rows, error)
 	QueryRow(string, ...any) *sql.Row
 }
 
@@ -36,6 +39,43 @@ func (r *SQLiteURLRepository) Migrate() error {
 	if err != nil {
 		return fmt.Errorf("create URLs table: %w", err)
 	}
+
+	// These columns were added after the table was first created, so they're added to existing tables if they're
+	// missing.
+	columns := []struct {
+		name       string
+		definition string
+	}{
+		{"expires_at", "INTEGER"},
+		{"max_clicks", "INTEGER NOT NULL DEFAULT 0"},
+		{"clicks", "INTEGER NOT NULL DEFAULT 0"},
+	}
+	for _, column := range columns {
+		if err := r.addColumnIfMissing("urls", column.name, column.definition); err != nil {
+			return err
+		}
+	}
+
+	const createExpiresAtIndexQuery = "CREATE INDEX IF NOT EXISTS urls_expires_at ON urls (expires_at);"
+	if _, err := r.db.Exec(createExpiresAtIndexQuery); err != nil {
+		return fmt.Errorf("create urls expires_at index: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteURLRepository) addColumnIfMissing(table, column, definition string) error {
+	const countColumnsQuery = "SELECT COUNT(*) FROM pragma_table_info($1) WHERE name = $2;"
+	var count int
+	if err := r.db.QueryRow(countColumnsQuery, table, column).Scan(&count); err != nil {
+		return fmt.Errorf("check whether %s table has %s column: %w", table, column, err)
+	}
+	if count > 0 {
+		return nil
+	}
+	log.Printf("Adding %s column to %s table.", column, table)
+	if _, err := r.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, definition)); err != nil {
+		return fmt.Errorf("add %s column to %s table: %w", column, table, err)
+	}
 	return nil
 }
 
@@ -45,11 +85,14 @@ func (r *SQLiteURLRepository) MustMigrate() {
 	}
 }
 
-func (r *SQLiteURLRepository) Create(shortPath, longURL string) error {
-	const insertURLQuery = "INSERT OR IGNORE INTO urls (short_path, long_url) VALUES ($1, $2);"
-	result, err := r.db.Exec(insertURLQuery, shortPath, longURL)
+func (r *SQLiteURLRepository) Create(url model.URL) error {
+	const insertURLQuery = `
+		INSERT OR IGNORE INTO urls (short_path, long_url, expires_at, max_clicks)
+		VALUES ($1, $2, $3, $4);
+	`
+	result, err := r.db.Exec(insertURLQuery, url.ShortPath, url.LongURL, toUnix(url.ExpiresAt), url.MaxClicks)
 	if err != nil {
-		return fmt.Errorf("insert (%q, %q) into urls (short_path, long_url): %w", shortPath, longURL, err)
+		return fmt.Errorf("insert %+v into urls: %w", url, err)
 	}
 	if rowsAffected, err := result.RowsAffected(); err != nil {
 		return fmt.Errorf("get rows affected by insert: %w", err)
@@ -59,14 +102,141 @@ func (r *SQLiteURLRepository) Create(shortPath, longURL string) error {
 	return nil
 }
 
-func (r *SQLiteURLRepository) Get(shortPath string) (string, error) {
-	const selectLongURLQuery = "SELECT long_url FROM urls WHERE short_path = $1;"
-	var longURL string
-	if err := r.db.QueryRow(selectLongURLQuery, shortPath).Scan(&longURL); err != nil {
+func (r *SQLiteURLRepository) Get(shortPath string) (model.URL, error) {
+	const selectURLQuery = "SELECT short_path, long_url, expires_at, max_clicks FROM urls WHERE short_path = $1;"
+	url, err := scanURL(r.db.QueryRow(selectURLQuery, shortPath))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", errors.New(codes.NotFound)
+			return model.URL{}, errors.New(codes.NotFound)
+		}
+		return model.URL{}, fmt.Errorf("select url with short_path = %q: %w", shortPath, err)
+	}
+	return url, nil
+}
+
+func (r *SQLiteURLRepository) Visit(shortPath string, now time.Time) (string, error) {
+	const visitURLQuery = `
+		UPDATE urls SET clicks = clicks + 1
+		WHERE short_path = $1 AND (expires_at IS NULL OR expires_at > $2) AND (max_clicks = 0 OR clicks < max_clicks)
+		RETURNING long_url;
+	`
+	var longURL string
+	if err := r.db.QueryRow(visitURLQuery, shortPath, now.Unix()).Scan(&longURL); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("update clicks of url with short_path = %q: %w", shortPath, err)
+		}
+		// The URL is either missing or expired.
+		if _, err := r.Get(shortPath); err != nil {
+			return "", err
 		}
-		return "", fmt.Errorf("select url with short_path = %q: %w", shortPath, err)
+		return "", errors.New(codes.Gone)
 	}
 	return longURL, nil
 }
+
+func (r *SQLiteURLRepository) DeleteExpired(now time.Time) ([]string, error) {
+	const deleteExpiredURLsQuery = `
+		DELETE FROM urls
+		WHERE expires_at <= $1 OR (max_clicks > 0 AND clicks >= max_clicks)
+		RETURNING short_path;
+	`
+	rows, err := r.db.Query(deleteExpiredURLsQuery, now.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("delete expired urls: %w", err)
+	}
+	defer rows.Close()
+
+	var shortPaths []string
+	for rows.Next() {
+		var shortPath string
+		if err := rows.Scan(&shortPath); err != nil {
+			return nil, fmt.Errorf("scan deleted short path: %w", err)
+		}
+		shortPaths = append(shortPaths, shortPath)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate over deleted short paths: %w", err)
+	}
+	return shortPaths, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanURL scans a row of short_path, long_url, expires_at and max_clicks into a model.URL.
+func scanURL(row scanner) (model.URL, error) {
+	var url model.URL
+	var expiresAt sql.NullInt64
+	if err := row.Scan(&url.ShortPath, &url.LongURL, &expiresAt, &url.MaxClicks); err != nil {
+		return model.URL{}, err
+	}
+	if expiresAt.Valid {
+		t := time.Unix(expiresAt.Int64, 0).UTC()
+		url.ExpiresAt = &t
+	}
+	return url, nil
+}
+
+// toUnix returns t as a Unix time, or nil if t is nil.
+func toUnix(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.Unix()
+}
+
+func (r *SQLiteURLRepository) Update(shortPath, longURL string) error {
+	const updateURLQuery = "UPDATE urls SET long_url = $1 WHERE short_path = $2;"
+	result, err := r.db.Exec(updateURLQuery, longURL, shortPath)
+	if err != nil {
+		return fmt.Errorf("update url with short_path = %q to long_url = %q: %w", shortPath, longURL, err)
+	}
+	return checkRowsAffected(result)
+}
+
+func (r *SQLiteURLRepository) Delete(shortPath string) error {
+	const deleteURLQuery = "DELETE FROM urls WHERE short_path = $1;"
+	result, err := r.db.Exec(deleteURLQuery, shortPath)
+	if err != nil {
+		return fmt.Errorf("delete url with short_path = %q: %w", shortPath, err)
+	}
+	return checkRowsAffected(result)
+}
+
+// checkRowsAffected returns a codes.NotFound error if no rows were affected by a statement.
+func checkRowsAffected(result sql.Result) error {
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	} else if rowsAffected == 0 {
+		return errors.New(codes.NotFound)
+	}
+	return nil
+}
+
+func (r *SQLiteURLRepository) List(prefix, after string, limit int) ([]model.URL, error) {
+	const selectURLsQuery = `
+		SELECT short_path, long_url, expires_at, max_clicks FROM urls
+		WHERE short_path > $1 AND substr(short_path, 1, length($2)) = $2
+		ORDER BY short_path
+		LIMIT $3;
+	`
+	rows, err := r.db.Query(selectURLsQuery, after, prefix, limit)
+	if err != nil {
+		return nil, fmt.Errorf("select urls with short_path > %q and prefix %q: %w", after, prefix, err)
+	}
+	defer rows.Close()
+
+	var urls []model.URL
+	for rows.Next() {
+		url, err := scanURL(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan url: %w", err)
+		}
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate over urls: %w", err)
+	}
+	return urls, nil
+}
@@ -5,36 +5,61 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/marcuscaisey/gophercises/urlshort/v2/errors"
 	"github.com/marcuscaisey/gophercises/urlshort/v2/errors/codes"
 )
 
+type handlerFunc func(http.ResponseWriter, *http.Request) error
+
 type errorHandlingMux struct {
-	serveMux *http.ServeMux
+	serveMux                *http.ServeMux
+	patternToMethodHandlers map[string]map[string]handlerFunc
 }
 
 func newErrorHandlingMux() *errorHandlingMux {
-	return &errorHandlingMux{serveMux: http.NewServeMux()}
+	return &errorHandlingMux{
+		serveMux:                http.NewServeMux(),
+		patternToMethodHandlers: map[string]map[string]handlerFunc{},
+	}
 }
 
 func (m *errorHandlingMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	m.serveMux.ServeHTTP(w, r)
 }
 
-func (m *errorHandlingMux) Handle(allowedMethod string, pattern string, handler func(http.ResponseWriter, *http.Request) error) {
-	f := func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != allowedMethod {
-			w.Header().Add("Allow", allowedMethod)
+// Handle registers handler for requests with the given method which match pattern. Handle can be called more than once
+// with the same pattern to handle more than one method.
+func (m *errorHandlingMux) Handle(method string, pattern string, handler handlerFunc) {
+	methodToHandler, ok := m.patternToMethodHandlers[pattern]
+	if !ok {
+		methodToHandler = map[string]handlerFunc{}
+		m.patternToMethodHandlers[pattern] = methodToHandler
+		m.serveMux.Handle(pattern, methodHandler(methodToHandler))
+	}
+	methodToHandler[method] = handler
+}
+
+func methodHandler(methodToHandler map[string]handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler, ok := methodToHandler[r.Method]
+		if !ok {
+			allowedMethods := make([]string, 0, len(methodToHandler))
+			for method := range methodToHandler {
+				allowedMethods = append(allowedMethods, method)
+			}
+			sort.Strings(allowedMethods)
+			w.Header().Add("Allow", strings.Join(allowedMethods, ", "))
 			w.WriteHeader(http.StatusMethodNotAllowed)
-			writeError(w, fmt.Sprintf("Method %s is not allowed, use %s.", r.Method, allowedMethod))
+			writeError(w, fmt.Sprintf("Method %s is not allowed, use %s.", r.Method, strings.Join(allowedMethods, ", ")))
 			return
 		}
 		if err := handler(w, r); err != nil {
 			handleError(w, err)
 		}
 	}
-	m.serveMux.Handle(pattern, http.HandlerFunc(f))
 }
 
 func handleError(w http.ResponseWriter, err error) {
@@ -50,6 +75,8 @@ func handleError(w http.ResponseWriter, err error) {
 		w.WriteHeader(http.StatusNotFound)
 	case codes.BadRequest:
 		w.WriteHeader(http.StatusBadRequest)
+	case codes.Gone:
+		w.WriteHeader(http.StatusGone)
 	}
 
 	var msg string
@@ -1,56 +1,127 @@
 package server
 
 import (
-	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/marcuscaisey/gophercises/urlshort/v2/errors"
 	"github.com/marcuscaisey/gophercises/urlshort/v2/errors/codes"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/model"
 )
 
 type URLRepository interface {
-	Create(longURL, shortPath string) error
-	Get(shortPath string) (string, error)
+	Create(url model.URL) error
+	Get(shortPath string) (model.URL, error)
+	// Visit returns the long URL of shortPath and counts the visit towards its max clicks. A codes.Gone error is
+	// returned if the URL has expired or been used up.
+	Visit(shortPath string, now time.Time) (string, error)
+	Update(shortPath, longURL string) error
+	Delete(shortPath string) error
+	// List returns at most limit URLs, ordered by short path, which have a short path starting with prefix and after
+	// the short path after.
+	List(prefix, after string, limit int) ([]model.URL, error)
 }
 
+type ClickRepository interface {
+	// GetStats returns the stats of the clicks on shortPath, with the clicks counted in buckets of bucketSize and at
+	// most topReferrers referrers.
+	GetStats(shortPath string, bucketSize time.Duration, topReferrers int) (model.Stats, error)
+	DeleteClicks(shortPaths []string) error
+}
+
+// ClickRecorder records the clicks on short paths without blocking.
+type ClickRecorder interface {
+	Record(shortPath string, r *http.Request)
+	// Flush writes the clicks which have been recorded but not written yet.
+	Flush()
+}
+
+// URLValidator validates the long URLs that short paths redirect to.
+type URLValidator interface {
+	// Validate returns the canonical form of longURL, or a codes.BadRequest error if it's not valid. requestHost is the
+	// host that the request with the URL was sent to.
+	Validate(longURL, requestHost string) (string, error)
+}
+
+// Generator generates the code used as the short path of a URL which isn't given one. attempt is 0 for the first code
+// generated for a URL and is incremented each time the code is already taken.
+type Generator interface {
+	Generate(longURL string, attempt int) string
+}
+
+// maxGenerateAttempts is the number of codes which are generated for a URL before giving up if they're all taken.
+const maxGenerateAttempts = 10
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 1000
+)
+
+const (
+	defaultStatsBucket       = 24 * time.Hour
+	minStatsBucket           = time.Minute
+	defaultStatsTopReferrers = 10
+	maxStatsTopReferrers     = 100
+)
+
 type Server struct {
-	mux     *http.ServeMux
-	urlRepo URLRepository
+	mux           *http.ServeMux
+	urlRepo       URLRepository
+	clickRepo     ClickRepository
+	clickRecorder ClickRecorder
+	urlValidator  URLValidator
+	generator     Generator
 }
 
-func New(urlRepo URLRepository) *Server {
+func New(urlRepo URLRepository, clickRepo ClickRepository, clickRecorder ClickRecorder, urlValidator URLValidator, generator Generator) *Server {
 	s := &Server{
-		urlRepo: urlRepo,
+		urlRepo:       urlRepo,
+		clickRepo:     clickRepo,
+		clickRecorder: clickRecorder,
+		urlValidator:  urlValidator,
+		generator:     generator,
 	}
 	return s
 }
 
 func (s *Server) Run(port uint) error {
-	mux := newErrorHandlingMux()
-	mux.Handle(http.MethodPost, "/shorten", s.shorten)
-	mux.Handle(http.MethodGet, "/", s.redirect)
-
 	address := fmt.Sprintf(":%d", port)
 	log.Printf("Serving on %s.", address)
 
-	err := http.ListenAndServe(address, mux)
+	err := http.ListenAndServe(address, s.newMux())
 	if err != nil {
 		return fmt.Errorf("listen and serve on %q: %w", address, err)
 	}
 	return nil
 }
 
+func (s *Server) newMux() *errorHandlingMux {
+	mux := newErrorHandlingMux()
+	mux.Handle(http.MethodPost, "/shorten", s.shorten)
+	mux.Handle(http.MethodGet, "/urls", s.listURLs)
+	mux.Handle(http.MethodGet, "/urls/", s.getURL)
+	mux.Handle(http.MethodPut, "/urls/", s.putURL)
+	mux.Handle(http.MethodPatch, "/urls/", s.patchURL)
+	mux.Handle(http.MethodDelete, "/urls/", s.deleteURL)
+	mux.Handle(http.MethodGet, "/", s.redirect)
+	return mux
+}
+
 func (s *Server) shorten(w http.ResponseWriter, r *http.Request) error {
 	w.Header().Add("Content-Type", "application/json")
 
 	var shortenReq struct {
 		ShortPath string `json:"short_path"`
 		LongURL   string `json:"long_url"`
+		ExpiresAt string `json:"expires_at"`
+		TTL       string `json:"ttl"`
+		MaxClicks int    `json:"max_clicks"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&shortenReq); err != nil {
 		return errors.New("Request is not valid JSON.", codes.BadRequest, err)
@@ -59,24 +130,50 @@ func (s *Server) shorten(w http.ResponseWriter, r *http.Request) error {
 	if shortenReq.LongURL == "" {
 		return errors.New(`Request must contain long_url field.`, codes.BadRequest)
 	}
-	if shortenReq.ShortPath == "" {
-		shortenReq.ShortPath = "/" + generateBase64(12)
-	} else if shortenReq.ShortPath == "/" {
-		return errors.New("short_path must contain at least one character", codes.BadRequest)
-	} else if shortenReq.ShortPath[0:1] != "/" {
-		shortenReq.ShortPath = "/" + shortenReq.ShortPath
+	longURL, err := s.urlValidator.Validate(shortenReq.LongURL, r.Host)
+	if err != nil {
+		return err
+	}
+	expiresAt, err := parseExpiry(shortenReq.ExpiresAt, shortenReq.TTL, time.Now())
+	if err != nil {
+		return err
+	}
+	if shortenReq.MaxClicks < 0 {
+		return errors.New("max_clicks must not be negative.", codes.BadRequest)
+	}
+	url := model.URL{
+		ShortPath: shortenReq.ShortPath,
+		LongURL:   longURL,
+		ExpiresAt: expiresAt,
+		MaxClicks: shortenReq.MaxClicks,
 	}
 
-	if err := s.urlRepo.Create(shortenReq.ShortPath, shortenReq.LongURL); err != nil {
-		if errors.Code(err) == codes.AlreadyExists {
-			return errors.New(fmt.Sprintf("short_path %s has already been taken.", shortenReq.ShortPath), err)
+	if url.ShortPath == "" {
+		shortPath, err := s.createGenerated(url)
+		if err != nil {
+			return err
+		}
+		url.ShortPath = shortPath
+	} else {
+		if url.ShortPath == "/" {
+			return errors.New("short_path must contain at least one character", codes.BadRequest)
+		} else if url.ShortPath[0:1] != "/" {
+			url.ShortPath = "/" + url.ShortPath
+		}
+		if err := checkShortPath(url.ShortPath); err != nil {
+			return err
+		}
+		if err := s.urlRepo.Create(url); err != nil {
+			if errors.Code(err) == codes.AlreadyExists {
+				return errors.New(fmt.Sprintf("short_path %s has already been taken.", url.ShortPath), err)
+			}
+			return fmt.Errorf("create url: %w", err)
 		}
-		return fmt.Errorf("create url: %w", err)
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(shortenReq); err != nil {
-		return fmt.Errorf("encode response: %+v to JSON: %w", shortenReq, err)
+	if err := json.NewEncoder(w).Encode(url); err != nil {
+		return fmt.Errorf("encode response: %+v to JSON: %w", url, err)
 	}
 
 	return nil
@@ -89,7 +186,37 @@ func (s *Server) redirect(w http.ResponseWriter, r *http.Request) error {
 		return nil
 	}
 
-	longURL, err := s.urlRepo.Get(shortPath)
+	longURL, err := s.urlRepo.Visit(shortPath, time.Now())
+	if err != nil {
+		switch errors.Code(err) {
+		case codes.NotFound:
+			return errors.New(fmt.Sprintf("No long URL found for short_path: %s", shortPath), err)
+		case codes.Gone:
+			return errors.New(fmt.Sprintf("short_path %s has expired.", shortPath), err)
+		}
+		return fmt.Errorf("visit long URL: %w", err)
+	}
+
+	s.clickRecorder.Record(shortPath, r)
+	http.Redirect(w, r, longURL, http.StatusFound)
+
+	return nil
+}
+
+// getURL handles GET /urls/{short_path}, which returns the URL, and GET /urls/{short_path}/stats, which returns the
+// stats of its clicks.
+func (s *Server) getURL(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Add("Content-Type", "application/json")
+
+	shortPath, err := urlShortPath(r)
+	if err != nil {
+		return err
+	}
+	if statsShortPath := strings.TrimSuffix(shortPath, "/stats"); statsShortPath != shortPath && statsShortPath != "" {
+		return s.getStats(w, r, statsShortPath)
+	}
+
+	url, err := s.urlRepo.Get(shortPath)
 	if err != nil {
 		if errors.Code(err) == codes.NotFound {
 			return errors.New(fmt.Sprintf("No long URL found for short_path: %s", shortPath), err)
@@ -97,15 +224,261 @@ func (s *Server) redirect(w http.ResponseWriter, r *http.Request) error {
 		return fmt.Errorf("get long URL: %w", err)
 	}
 
-	http.Redirect(w, r, longURL, http.StatusFound)
+	if err := json.NewEncoder(w).Encode(url); err != nil {
+		return fmt.Errorf("encode response: %+v to JSON: %w", url, err)
+	}
+
+	return nil
+}
+
+// getStats returns the stats of the clicks on a short path. The histogram's bucket size is set by the bucket query
+// parameter, which is a duration like 1h, and the number of top referrers by the top query parameter.
+func (s *Server) getStats(w http.ResponseWriter, r *http.Request, shortPath string) error {
+	query := r.URL.Query()
+	bucket := defaultStatsBucket
+	if bucketStr := query.Get("bucket"); bucketStr != "" {
+		var err error
+		bucket, err = time.ParseDuration(bucketStr)
+		if err != nil || bucket < minStatsBucket || bucket%time.Second != 0 {
+			return errors.New(fmt.Sprintf("bucket must be a whole number of seconds of at least %s, like 1h or 24h.", minStatsBucket), codes.BadRequest)
+		}
+	}
+	topReferrers := defaultStatsTopReferrers
+	if topStr := query.Get("top"); topStr != "" {
+		var err error
+		topReferrers, err = strconv.Atoi(topStr)
+		if err != nil || topReferrers < 1 || topReferrers > maxStatsTopReferrers {
+			return errors.New(fmt.Sprintf("top must be an integer from 1 to %d.", maxStatsTopReferrers), codes.BadRequest)
+		}
+	}
+
+	if _, err := s.urlRepo.Get(shortPath); err != nil {
+		if errors.Code(err) == codes.NotFound {
+			return errors.New(fmt.Sprintf("No long URL found for short_path: %s", shortPath), err)
+		}
+		return fmt.Errorf("get long URL: %w", err)
+	}
+	stats, err := s.clickRepo.GetStats(shortPath, bucket, topReferrers)
+	if err != nil {
+		return fmt.Errorf("get stats: %w", err)
+	}
+
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		return fmt.Errorf("encode response: %+v to JSON: %w", stats, err)
+	}
 
 	return nil
 }
 
-func generateBase64(length int) string {
-	randomBytes := make([]byte, length)
-	if _, err := io.ReadFull(rand.Reader, randomBytes); err != nil {
-		panic(err)
+func (s *Server) putURL(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Add("Content-Type", "application/json")
+
+	shortPath, err := urlShortPath(r)
+	if err != nil {
+		return err
+	}
+	var putReq struct {
+		LongURL string `json:"long_url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&putReq); err != nil {
+		return errors.New("Request is not valid JSON.", codes.BadRequest, err)
+	}
+	if putReq.LongURL == "" {
+		return errors.New(`Request must contain long_url field.`, codes.BadRequest)
+	}
+
+	return s.updateURL(w, r, shortPath, putReq.LongURL)
+}
+
+func (s *Server) patchURL(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Add("Content-Type", "application/json")
+
+	shortPath, err := urlShortPath(r)
+	if err != nil {
+		return err
+	}
+	var patchReq struct {
+		LongURL *string `json:"long_url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&patchReq); err != nil {
+		return errors.New("Request is not valid JSON.", codes.BadRequest, err)
+	}
+	if patchReq.LongURL == nil {
+		return errors.New("Request must contain at least one field to update: long_url.", codes.BadRequest)
+	}
+	if *patchReq.LongURL == "" {
+		return errors.New("long_url must not be empty.", codes.BadRequest)
+	}
+
+	return s.updateURL(w, r, shortPath, *patchReq.LongURL)
+}
+
+func (s *Server) updateURL(w http.ResponseWriter, r *http.Request, shortPath, longURL string) error {
+	longURL, err := s.urlValidator.Validate(longURL, r.Host)
+	if err != nil {
+		return err
+	}
+	if err := s.urlRepo.Update(shortPath, longURL); err != nil {
+		if errors.Code(err) == codes.NotFound {
+			return errors.New(fmt.Sprintf("No long URL found for short_path: %s", shortPath), err)
+		}
+		return fmt.Errorf("update url: %w", err)
+	}
+	url, err := s.urlRepo.Get(shortPath)
+	if err != nil {
+		return fmt.Errorf("get updated url: %w", err)
+	}
+
+	if err := json.NewEncoder(w).Encode(url); err != nil {
+		return fmt.Errorf("encode response: %+v to JSON: %w", url, err)
+	}
+
+	return nil
+}
+
+func (s *Server) deleteURL(w http.ResponseWriter, r *http.Request) error {
+	shortPath, err := urlShortPath(r)
+	if err != nil {
+		return err
+	}
+	if err := s.urlRepo.Delete(shortPath); err != nil {
+		if errors.Code(err) == codes.NotFound {
+			return errors.New(fmt.Sprintf("No long URL found for short_path: %s", shortPath), err)
+		}
+		return fmt.Errorf("delete url: %w", err)
+	}
+	// The clicks are deleted so that they're not inherited by a URL which reuses the short path. They're flushed first
+	// so that clicks which haven't been written yet are deleted too.
+	s.clickRecorder.Flush()
+	if err := s.clickRepo.DeleteClicks([]string{shortPath}); err != nil {
+		return fmt.Errorf("delete clicks: %w", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// urlShortPath returns the short path of the URL which a request to /urls/{short_path} is for.
+func urlShortPath(r *http.Request) (string, error) {
+	shortPath := strings.TrimPrefix(r.URL.Path, "/urls")
+	if shortPath == "/" {
+		return "", errors.New("short_path must contain at least one character", codes.BadRequest)
+	}
+	return shortPath, nil
+}
+
+// checkShortPath returns a codes.BadRequest error if shortPath is one of the API's paths, which it would be hidden by,
+// or if it ends in /stats after another segment, which would make GET /urls/{short_path}/stats ambiguous. /stats itself
+// isn't ambiguous because GET /urls/stats can only be for the URL.
+func checkShortPath(shortPath string) error {
+	if shortPath == "/shorten" || shortPath == "/urls" || strings.HasPrefix(shortPath, "/urls/") {
+		return errors.New(fmt.Sprintf("short_path %s is reserved for the API.", shortPath), codes.BadRequest)
+	}
+	if shortPath != "/stats" && strings.HasSuffix(shortPath, "/stats") {
+		return errors.New("short_path must not end in /stats, which is used by the stats endpoint.", codes.BadRequest)
+	}
+	return nil
+}
+
+// listURLs lists the URLs in order of their short paths, a page at a time. The page size is set by the limit query
+// parameter and the next page is requested by passing the next_cursor from the response as the cursor query parameter.
+// The URLs can be filtered to those with short paths starting with the prefix query parameter.
+func (s *Server) listURLs(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Add("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	limit := defaultListLimit
+	if limitStr := query.Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return errors.New(fmt.Sprintf("limit must be an integer from 1 to %d.", maxListLimit), codes.BadRequest)
+		}
+	}
+	var after string
+	if cursor := query.Get("cursor"); cursor != "" {
+		afterBytes, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return errors.New("cursor is not valid.", codes.BadRequest, err)
+		}
+		after = string(afterBytes)
+	}
+	prefix := query.Get("prefix")
+	if prefix != "" && prefix[0:1] != "/" {
+		prefix = "/" + prefix
+	}
+
+	// One more URL than the limit is fetched to find out whether there's another page.
+	urls, err := s.urlRepo.List(prefix, after, limit+1)
+	if err != nil {
+		return fmt.Errorf("list urls: %w", err)
+	}
+	listResp := struct {
+		URLs       []model.URL `json:"urls"`
+		NextCursor string      `json:"next_cursor,omitempty"`
+	}{
+		URLs: urls,
+	}
+	if len(urls) > limit {
+		listResp.URLs = urls[:limit]
+		listResp.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(urls[limit-1].ShortPath))
+	}
+	if listResp.URLs == nil {
+		listResp.URLs = []model.URL{}
+	}
+
+	if err := json.NewEncoder(w).Encode(listResp); err != nil {
+		return fmt.Errorf("encode response: %+v to JSON: %w", listResp, err)
+	}
+
+	return nil
+}
+
+// parseExpiry returns the time that a URL expires, which is either given as an RFC 3339 time by expiresAt or as a
+// duration after now by ttl, or nil if neither are given.
+func parseExpiry(expiresAt, ttl string, now time.Time) (*time.Time, error) {
+	var t time.Time
+	switch {
+	case expiresAt != "" && ttl != "":
+		return nil, errors.New("Request must not contain both expires_at and ttl fields.", codes.BadRequest)
+	case expiresAt != "":
+		var err error
+		t, err = time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return nil, errors.New("expires_at must be an RFC 3339 time, like 2022-06-01T12:00:00Z.", codes.BadRequest, err)
+		}
+	case ttl != "":
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			return nil, errors.New("ttl must be a positive duration, like 30m or 24h.", codes.BadRequest)
+		}
+		t = now.Add(d)
+	default:
+		return nil, nil
+	}
+	if !t.After(now) {
+		return nil, errors.New("expires_at must be in the future.", codes.BadRequest)
+	}
+	// Expiry times are stored to the second.
+	t = t.UTC().Truncate(time.Second)
+	return &t, nil
+}
+
+// createGenerated creates url with a generated short path, generating another each time that the last is taken.
+func (s *Server) createGenerated(url model.URL) (string, error) {
+	for attempt := 0; attempt < maxGenerateAttempts; attempt++ {
+		url.ShortPath = "/" + s.generator.Generate(url.LongURL, attempt)
+		if checkShortPath(url.ShortPath) != nil {
+			continue
+		}
+		err := s.urlRepo.Create(url)
+		if err == nil {
+			return url.ShortPath, nil
+		}
+		if errors.Code(err) != codes.AlreadyExists {
+			return "", fmt.Errorf("create url: %w", err)
+		}
 	}
-	return base64.StdEncoding.EncodeToString(randomBytes)
+	return "", fmt.Errorf("generate short path: all %d generated short paths were already taken", maxGenerateAttempts)
 }
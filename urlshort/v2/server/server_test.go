@@ -0,0 +1,431 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcuscaisey/gophercises/urlshort/v2/analytics"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/model"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/repo"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/validator"
+)
+
+type clickRecorderFunc func(shortPath string, r *http.Request)
+
+func (f clickRecorderFunc) Record(shortPath string, r *http.Request) {
+	f(shortPath, r)
+}
+
+func (f clickRecorderFunc) Flush() {}
+
+func ignoreClicks(string, *http.Request) {}
+
+func newValidator(t *testing.T, blocklistPath string) *validator.Validator {
+	v, err := validator.New([]string{"sho.rt"}, blocklistPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// newRequest returns a request which is sent to the shortener's host, sho.rt, rather than example.com, which is used
+// in the tests' long URLs.
+func newRequest(method, target string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	r.Host = "sho.rt"
+	return r
+}
+
+type fixedGenerator []string
+
+func (g fixedGenerator) Generate(longURL string, attempt int) string {
+	return g[attempt%len(g)]
+}
+
+func TestShortenRetriesTakenGeneratedShortPaths(t *testing.T) {
+	urlRepo := repo.NewInMemoryURLRepository()
+	if err := urlRepo.Create(model.URL{ShortPath: "/taken", LongURL: "https://example.com/taken"}); err != nil {
+		t.Fatal(err)
+	}
+	s := New(urlRepo, repo.NewInMemoryClickRepository(), clickRecorderFunc(ignoreClicks), newValidator(t, ""), fixedGenerator{"taken", "free"})
+
+	w := httptest.NewRecorder()
+	r := newRequest(http.MethodPost, "/shorten", strings.NewReader(`{"long_url": "https://example.com"}`))
+	if err := s.shorten(w, r); err != nil {
+		t.Fatalf("shorten returned unexpected err: %s", err)
+	}
+	if got, err := urlRepo.Get("/free"); err != nil || got.LongURL != "https://example.com" {
+		t.Errorf(`Get("/free") = %+v, %v, want long URL "https://example.com"`, got, err)
+	}
+
+	s = New(urlRepo, repo.NewInMemoryClickRepository(), clickRecorderFunc(ignoreClicks), newValidator(t, ""), fixedGenerator{"taken"})
+	r = newRequest(http.MethodPost, "/shorten", strings.NewReader(`{"long_url": "https://example.com"}`))
+	if err := s.shorten(httptest.NewRecorder(), r); err == nil {
+		t.Errorf("shorten returned nil err when every generated short path was taken, want an error")
+	}
+}
+
+func TestURLEndpoints(t *testing.T) {
+	urlRepo := repo.NewInMemoryURLRepository()
+	for _, shortPath := range []string{"/a", "/b", "/c", "/d"} {
+		if err := urlRepo.Create(model.URL{ShortPath: shortPath, LongURL: "https://example.com" + shortPath}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mux := New(urlRepo, repo.NewInMemoryClickRepository(), clickRecorderFunc(ignoreClicks), newValidator(t, ""), fixedGenerator{"free"}).newMux()
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	testCases := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "put",
+			method:     http.MethodPut,
+			target:     "/urls/a",
+			body:       `{"long_url": "https://example.com/put"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"short_path":"/a","long_url":"https://example.com/put"}`,
+		},
+		{
+			name:       "put without long_url",
+			method:     http.MethodPut,
+			target:     "/urls/a",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"Request must contain long_url field."}`,
+		},
+		{
+			name:       "patch",
+			method:     http.MethodPatch,
+			target:     "/urls/b",
+			body:       `{"long_url": "https://example.com/patch"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"short_path":"/b","long_url":"https://example.com/patch"}`,
+		},
+		{
+			name:       "patch missing",
+			method:     http.MethodPatch,
+			target:     "/urls/missing",
+			body:       `{"long_url": "https://example.com/patch"}`,
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"No long URL found for short_path: /missing"}`,
+		},
+		{
+			name:       "delete",
+			method:     http.MethodDelete,
+			target:     "/urls/c",
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "delete missing",
+			method:     http.MethodDelete,
+			target:     "/urls/c",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"No long URL found for short_path: /c"}`,
+		},
+		{
+			name:       "method not allowed",
+			method:     http.MethodPost,
+			target:     "/urls/a",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantBody:   `{"error":"Method POST is not allowed, use DELETE, GET, PATCH, PUT."}`,
+		},
+		{
+			name:       "list first page",
+			method:     http.MethodGet,
+			target:     "/urls?limit=2",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"urls":[{"short_path":"/a","long_url":"https://example.com/put"},{"short_path":"/b","long_url":"https://example.com/patch"}],"next_cursor":"L2I"}`,
+		},
+		{
+			name:       "list last page",
+			method:     http.MethodGet,
+			target:     "/urls?limit=2&cursor=L2I",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"urls":[{"short_path":"/d","long_url":"https://example.com/d"}]}`,
+		},
+		{
+			name:       "list with prefix",
+			method:     http.MethodGet,
+			target:     "/urls?prefix=e",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"urls":[]}`,
+		},
+		{
+			name:       "list with invalid limit",
+			method:     http.MethodGet,
+			target:     "/urls?limit=0",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"limit must be an integer from 1 to 1000."}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serve(tc.method, tc.target, tc.body)
+			if w.Code != tc.wantStatus {
+				t.Errorf("%s %s returned status %d, want %d", tc.method, tc.target, w.Code, tc.wantStatus)
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tc.wantBody {
+				t.Errorf("%s %s returned body %s, want %s", tc.method, tc.target, got, tc.wantBody)
+			}
+		})
+	}
+}
+
+func TestRedirectRecordsClicksForStats(t *testing.T) {
+	urlRepo := repo.NewInMemoryURLRepository()
+	if err := urlRepo.Create(model.URL{ShortPath: "/a", LongURL: "https://example.com/a"}); err != nil {
+		t.Fatal(err)
+	}
+	clickRepo := repo.NewInMemoryClickRepository()
+	recordClick := func(shortPath string, r *http.Request) {
+		click := model.Click{
+			ShortPath:   shortPath,
+			Time:        time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC),
+			Referrer:    r.Referer(),
+			VisitorHash: r.RemoteAddr,
+		}
+		if err := clickRepo.CreateClicks([]model.Click{click}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mux := New(urlRepo, clickRepo, clickRecorderFunc(recordClick), newValidator(t, ""), fixedGenerator{"free"}).newMux()
+
+	for _, referrer := range []string{"https://a.example.com", "https://b.example.com", "https://b.example.com", ""} {
+		w := httptest.NewRecorder()
+		r := newRequest(http.MethodGet, "/a", nil)
+		r.Header.Set("Referer", referrer)
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusFound {
+			t.Fatalf("GET /a returned status %d, want %d", w.Code, http.StatusFound)
+		}
+	}
+
+	testCases := []struct {
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			target:     "/urls/a/stats?bucket=1h&top=1",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"total_clicks":4,"unique_visitors":1,"histogram":[{"start":"2022-06-01T12:00:00Z","clicks":4}],"top_referrers":[{"referrer":"https://b.example.com","clicks":2}]}`,
+		},
+		{
+			target:     "/urls/a",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"short_path":"/a","long_url":"https://example.com/a"}`,
+		},
+		{
+			target:     "/urls/missing/stats",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"No long URL found for short_path: /missing"}`,
+		},
+		{
+			target:     "/urls/a/stats?bucket=1s",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"bucket must be a whole number of seconds of at least 1m0s, like 1h or 24h."}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newRequest(http.MethodGet, tc.target, nil))
+		if w.Code != tc.wantStatus {
+			t.Errorf("GET %s returned status %d, want %d", tc.target, w.Code, tc.wantStatus)
+		}
+		if got := strings.TrimSpace(w.Body.String()); got != tc.wantBody {
+			t.Errorf("GET %s returned body %s, want %s", tc.target, got, tc.wantBody)
+		}
+	}
+}
+
+func TestExpiringURLs(t *testing.T) {
+	mux := New(repo.NewInMemoryURLRepository(), repo.NewInMemoryClickRepository(), clickRecorderFunc(ignoreClicks), newValidator(t, ""), fixedGenerator{"free"}).newMux()
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	w := serve(http.MethodPost, "/shorten", `{"short_path": "once", "long_url": "https://example.com", "ttl": "1h", "max_clicks": 1}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /shorten returned status %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	var url model.URL
+	if err := json.NewDecoder(w.Body).Decode(&url); err != nil {
+		t.Fatal(err)
+	}
+	if url.ExpiresAt == nil || time.Until(*url.ExpiresAt) > time.Hour || time.Until(*url.ExpiresAt) < 59*time.Minute {
+		t.Errorf("POST /shorten with ttl 1h returned expires_at %v, want an hour from now", url.ExpiresAt)
+	}
+	if url.MaxClicks != 1 {
+		t.Errorf("POST /shorten returned max_clicks %d, want 1", url.MaxClicks)
+	}
+
+	if w := serve(http.MethodGet, "/once", ""); w.Code != http.StatusFound {
+		t.Errorf("first GET /once returned status %d, want %d", w.Code, http.StatusFound)
+	}
+	w = serve(http.MethodGet, "/once", "")
+	if w.Code != http.StatusGone {
+		t.Errorf("second GET /once returned status %d, want %d", w.Code, http.StatusGone)
+	}
+	if got, want := strings.TrimSpace(w.Body.String()), `{"error":"short_path /once has expired."}`; got != want {
+		t.Errorf("second GET /once returned body %s, want %s", got, want)
+	}
+
+	badRequests := []struct {
+		body    string
+		wantErr string
+	}{
+		{`{"long_url": "https://example.com", "ttl": "1h", "expires_at": "2030-01-01T00:00:00Z"}`, "Request must not contain both expires_at and ttl fields."},
+		{`{"long_url": "https://example.com", "expires_at": "2000-01-01T00:00:00Z"}`, "expires_at must be in the future."},
+		{`{"long_url": "https://example.com", "expires_at": "tomorrow"}`, "expires_at must be an RFC 3339 time, like 2022-06-01T12:00:00Z."},
+		{`{"long_url": "https://example.com", "ttl": "-1h"}`, "ttl must be a positive duration, like 30m or 24h."},
+		{`{"long_url": "https://example.com", "max_clicks": -1}`, "max_clicks must not be negative."},
+	}
+	for _, tc := range badRequests {
+		w := serve(http.MethodPost, "/shorten", tc.body)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("POST /shorten %s returned status %d, want %d", tc.body, w.Code, http.StatusBadRequest)
+		}
+		var resp struct{ Error string }
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Error != tc.wantErr {
+			t.Errorf("POST /shorten %s returned error %q, want %q", tc.body, resp.Error, tc.wantErr)
+		}
+	}
+}
+
+func TestShortenValidatesLongURL(t *testing.T) {
+	blocklistPath := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(blocklistPath, []byte("evil.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	urlRepo := repo.NewInMemoryURLRepository()
+	mux := New(urlRepo, repo.NewInMemoryClickRepository(), clickRecorderFunc(ignoreClicks), newValidator(t, blocklistPath), fixedGenerator{"free"}).newMux()
+
+	testCases := []struct {
+		longURL    string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			longURL:    "HTTPS://Example.COM:443/Path?q=1",
+			wantStatus: http.StatusCreated,
+			wantBody:   `{"short_path":"/free","long_url":"https://example.com/Path?q=1"}`,
+		},
+		{
+			longURL:    "javascript:alert(1)",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"long_url must be an absolute http or https URL."}`,
+		},
+		{
+			longURL:    "http://example.com:80/",
+			wantStatus: http.StatusConflict,
+			wantBody:   `{"error":"short_path /free has already been taken."}`,
+		},
+		{
+			longURL:    "http://SHO.RT/free",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"long_url must not point to this URL shortener."}`,
+		},
+		{
+			longURL:    "http://localhost:8080/free",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"long_url must not point to this URL shortener."}`,
+		},
+		{
+			longURL:    "https://www.evil.com/",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"long_url host www.evil.com is blocked because evil.com is on the blocklist."}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		body := fmt.Sprintf(`{"short_path": "free", "long_url": %q}`, tc.longURL)
+		r := newRequest(http.MethodPost, "/shorten", strings.NewReader(body))
+		r.Host = "localhost:8080"
+		mux.ServeHTTP(w, r)
+		if w.Code != tc.wantStatus {
+			t.Errorf("POST /shorten %s returned status %d, want %d", body, w.Code, tc.wantStatus)
+		}
+		if got := strings.TrimSpace(w.Body.String()); got != tc.wantBody {
+			t.Errorf("POST /shorten %s returned body %s, want %s", body, got, tc.wantBody)
+		}
+	}
+}
+
+func TestShortenRejectsReservedShortPaths(t *testing.T) {
+	urlRepo := repo.NewInMemoryURLRepository()
+	mux := New(urlRepo, repo.NewInMemoryClickRepository(), clickRecorderFunc(ignoreClicks), newValidator(t, ""), fixedGenerator{"urls", "free"}).newMux()
+
+	for _, shortPath := range []string{"shorten", "/urls", "urls/a", "foo/stats"} {
+		w := httptest.NewRecorder()
+		body := fmt.Sprintf(`{"short_path": %q, "long_url": "https://example.com"}`, shortPath)
+		mux.ServeHTTP(w, newRequest(http.MethodPost, "/shorten", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("POST /shorten %s returned status %d, want %d", body, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, newRequest(http.MethodPost, "/shorten", strings.NewReader(`{"long_url": "https://example.com"}`)))
+	if got, want := strings.TrimSpace(w.Body.String()), `{"short_path":"/free","long_url":"https://example.com"}`; got != want {
+		t.Errorf("POST /shorten with a reserved generated short path returned body %s, want %s", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, newRequest(http.MethodPost, "/shorten", strings.NewReader(`{"short_path": "stats", "long_url": "https://example.com"}`)))
+	if w.Code != http.StatusCreated {
+		t.Errorf("POST /shorten with short_path stats returned status %d, want %d", w.Code, http.StatusCreated)
+	}
+}
+
+func TestDeleteURLDeletesItsClicks(t *testing.T) {
+	urlRepo := repo.NewInMemoryURLRepository()
+	clickRepo := repo.NewInMemoryClickRepository()
+	if err := urlRepo.Create(model.URL{ShortPath: "/a", LongURL: "https://example.com/a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := clickRepo.CreateClicks([]model.Click{{ShortPath: "/a", Time: time.Now(), VisitorHash: "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	// The recorder only writes clicks on Flush and Close, so the click on GET /a is still buffered when the URL is
+	// deleted.
+	clickRecorder := analytics.NewRecorder(clickRepo, "salt", 10, time.Hour)
+	mux := New(urlRepo, clickRepo, clickRecorder, newValidator(t, ""), fixedGenerator{"free"}).newMux()
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, newRequest(http.MethodGet, "/a", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("GET /a returned status %d, want %d", w.Code, http.StatusFound)
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, newRequest(http.MethodDelete, "/urls/a", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /urls/a returned status %d, want %d", w.Code, http.StatusNoContent)
+	}
+	clickRecorder.Close()
+	if stats, err := clickRepo.GetStats("/a", time.Hour, 10); err != nil || stats.TotalClicks != 0 {
+		t.Errorf("GetStats after DELETE /urls/a = %+v, %v, want no clicks", stats, err)
+	}
+}
@@ -0,0 +1,84 @@
+package sweeper
+
+import (
+	"log"
+	"time"
+)
+
+type URLRepository interface {
+	// DeleteExpired deletes the URLs which have expired or been used up by now and returns their short paths.
+	DeleteExpired(now time.Time) ([]string, error)
+}
+
+type ClickRepository interface {
+	DeleteClicks(shortPaths []string) error
+}
+
+type ClickRecorder interface {
+	// Flush writes the clicks which have been recorded but not written yet.
+	Flush()
+}
+
+// Sweeper periodically deletes the URLs which have expired or been used up, along with their clicks so that they're
+// not inherited by a URL which reuses the short path.
+type Sweeper struct {
+	urlRepo       URLRepository
+	clickRepo     ClickRepository
+	clickRecorder ClickRecorder
+	interval      time.Duration
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+// New returns a Sweeper which deletes expired URLs every interval.
+func New(urlRepo URLRepository, clickRepo ClickRepository, clickRecorder ClickRecorder, interval time.Duration) *Sweeper {
+	s := &Sweeper{
+		urlRepo:       urlRepo,
+		clickRepo:     clickRepo,
+		clickRecorder: clickRecorder,
+		interval:      interval,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Close stops the sweeper, waiting for a sweep which is in progress to finish.
+func (s *Sweeper) Close() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *Sweeper) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.Sweep(now)
+		}
+	}
+}
+
+// Sweep deletes the URLs which have expired or been used up by now.
+func (s *Sweeper) Sweep(now time.Time) {
+	shortPaths, err := s.urlRepo.DeleteExpired(now)
+	if err != nil {
+		log.Printf("Failed to delete expired URLs: %s", err)
+		return
+	}
+	if len(shortPaths) == 0 {
+		return
+	}
+	log.Printf("Deleted %d expired URLs.", len(shortPaths))
+	// The clicks are flushed first so that clicks which haven't been written yet are deleted too.
+	s.clickRecorder.Flush()
+	if err := s.clickRepo.DeleteClicks(shortPaths); err != nil {
+		log.Printf("Failed to delete clicks of expired URLs: %s", err)
+	}
+}
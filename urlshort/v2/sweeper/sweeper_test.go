@@ -0,0 +1,97 @@
+package sweeper
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marcuscaisey/gophercises/urlshort/v2/analytics"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/errors"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/errors/codes"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/model"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/repo"
+)
+
+type urlRepositoryFunc func(now time.Time) ([]string, error)
+
+func (f urlRepositoryFunc) DeleteExpired(now time.Time) ([]string, error) {
+	return f(now)
+}
+
+type clickRepositoryFunc func(shortPaths []string) error
+
+func (f clickRepositoryFunc) DeleteClicks(shortPaths []string) error {
+	return f(shortPaths)
+}
+
+type clickRecorderFunc func()
+
+func (f clickRecorderFunc) Flush() {
+	f()
+}
+
+func TestSweeperSweepsEveryInterval(t *testing.T) {
+	sweeps := make(chan time.Time, 10)
+	urlRepo := urlRepositoryFunc(func(now time.Time) ([]string, error) {
+		sweeps <- now
+		return nil, nil
+	})
+	clickRepo := clickRepositoryFunc(func([]string) error { return nil })
+	s := New(urlRepo, clickRepo, clickRecorderFunc(func() {}), time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-sweeps:
+		case <-time.After(time.Second):
+			t.Fatalf("Sweeper swept %d times within a second, want at least 2", i)
+		}
+	}
+	s.Close()
+}
+
+func TestSweepDeletesExpiredURLsAndTheirClicks(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	urlRepo := repo.NewInMemoryURLRepository()
+	clickRepo := repo.NewInMemoryClickRepository()
+	for _, url := range []model.URL{
+		{ShortPath: "/expired", LongURL: "https://example.com", ExpiresAt: &past},
+		{ShortPath: "/live", LongURL: "https://example.com"},
+	} {
+		if err := urlRepo.Create(url); err != nil {
+			t.Fatal(err)
+		}
+		if err := clickRepo.CreateClicks([]model.Click{{ShortPath: url.ShortPath, Time: past, VisitorHash: "1"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The recorder only writes clicks on Flush and Close, so this click on the expired URL is still buffered when it's
+	// swept.
+	clickRecorder := analytics.NewRecorder(clickRepo, "salt", 10, time.Hour)
+	clickRecorder.Record("/expired", httptest.NewRequest("GET", "/expired", nil))
+
+	s := &Sweeper{urlRepo: urlRepo, clickRepo: clickRepo, clickRecorder: clickRecorder}
+	s.Sweep(now)
+	clickRecorder.Close()
+
+	if _, err := urlRepo.Get("/expired"); errors.Code(err) != codes.NotFound {
+		t.Errorf("Get of expired URL after Sweep returned err %v, want code %s", err, codes.NotFound)
+	}
+	if stats, err := clickRepo.GetStats("/expired", time.Hour, 10); err != nil || stats.TotalClicks != 0 {
+		t.Errorf("GetStats of expired URL after Sweep = %+v, %v, want no clicks", stats, err)
+	}
+	if _, err := urlRepo.Get("/live"); err != nil {
+		t.Errorf("Get of live URL after Sweep returned unexpected err: %s", err)
+	}
+	want := model.Stats{
+		TotalClicks:    1,
+		UniqueVisitors: 1,
+		Histogram:      []model.HistogramBucket{{Start: past, Clicks: 1}},
+		TopReferrers:   []model.ReferrerClicks{},
+	}
+	if stats, err := clickRepo.GetStats("/live", time.Hour, 10); err != nil || !reflect.DeepEqual(stats, want) {
+		t.Errorf("GetStats of live URL after Sweep = %+v, %v, want %+v", stats, err, want)
+	}
+}
@@ -0,0 +1,158 @@
+package validator
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/marcuscaisey/gophercises/urlshort/v2/errors"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/errors/codes"
+)
+
+var schemeToDefaultPort = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Validator validates and canonicalizes the long URLs that short paths redirect to.
+type Validator struct {
+	selfHosts     map[string]bool
+	blocklistPath string
+	// blocklist holds the map[string]bool of blocked domains.
+	blocklist atomic.Value
+	// mu serialises reloads of the blocklist.
+	mu sync.Mutex
+}
+
+// New returns a Validator which rejects URLs to any of selfHosts, which are the hosts that the shortener is served on,
+// and to the domains in the blocklist file at blocklistPath, which has a domain on each line. Blank lines and lines
+// starting with # are ignored. No domains are blocked if blocklistPath is empty.
+func New(selfHosts []string, blocklistPath string) (*Validator, error) {
+	v := &Validator{
+		selfHosts:     map[string]bool{},
+		blocklistPath: blocklistPath,
+	}
+	for _, host := range selfHosts {
+		v.selfHosts[canonicalSelfHost(host)] = true
+	}
+	v.blocklist.Store(map[string]bool{})
+	if blocklistPath != "" {
+		if err := v.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// Reload reads the blocklist file again. The old blocklist is kept if the file can't be read.
+func (v *Validator) Reload() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	f, err := os.Open(v.blocklistPath)
+	if err != nil {
+		return fmt.Errorf("open blocklist: %w", err)
+	}
+	defer f.Close()
+
+	blocklist := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domain := canonicalHostname(strings.TrimPrefix(line, "*."))
+		if domain == "" || strings.ContainsAny(domain, " \t/:") {
+			return fmt.Errorf("blocklist %s line %d: invalid domain %q", v.blocklistPath, lineNum, line)
+		}
+		blocklist[domain] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read blocklist: %w", err)
+	}
+	v.blocklist.Store(blocklist)
+	return nil
+}
+
+// Validate returns the canonical form of longURL, or a codes.BadRequest error if it's not an absolute http or https URL,
+// it points to the shortener itself or its host is blocked. requestHost is the host that the request to shorten the URL
+// was sent to, which is treated as one of the shortener's hosts.
+func (v *Validator) Validate(longURL, requestHost string) (string, error) {
+	u, err := url.Parse(longURL)
+	if err != nil {
+		return "", errors.New("long_url is not a valid URL.", codes.BadRequest, err)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	defaultPort, ok := schemeToDefaultPort[u.Scheme]
+	if !ok || u.Host == "" {
+		return "", errors.New("long_url must be an absolute http or https URL.", codes.BadRequest)
+	}
+
+	hostname := canonicalHostname(u.Hostname())
+	if hostname == "" {
+		return "", errors.New("long_url must have a host.", codes.BadRequest)
+	}
+	port := u.Port()
+	if port == defaultPort {
+		port = ""
+	}
+	if port != "" {
+		u.Host = net.JoinHostPort(hostname, port)
+	} else if strings.Contains(hostname, ":") {
+		u.Host = "[" + hostname + "]"
+	} else {
+		u.Host = hostname
+	}
+
+	if v.selfHosts[u.Host] || u.Host == canonicalSelfHost(requestHost) {
+		return "", errors.New("long_url must not point to this URL shortener.", codes.BadRequest)
+	}
+	if domain, blocked := v.blockedDomain(hostname); blocked {
+		return "", errors.New(fmt.Sprintf("long_url host %s is blocked because %s is on the blocklist.", hostname, domain), codes.BadRequest)
+	}
+
+	return u.String(), nil
+}
+
+// blockedDomain returns the domain on the blocklist which hostname is or is a subdomain of, if there is one.
+func (v *Validator) blockedDomain(hostname string) (string, bool) {
+	blocklist := v.blocklist.Load().(map[string]bool)
+	for domain := hostname; domain != ""; {
+		if blocklist[domain] {
+			return domain, true
+		}
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok {
+			break
+		}
+		domain = parent
+	}
+	return "", false
+}
+
+func canonicalHostname(hostname string) string {
+	return strings.TrimSuffix(strings.ToLower(hostname), ".")
+}
+
+// canonicalSelfHost returns the canonical form of a host of the shortener, which is served on either the default HTTP
+// or HTTPS port if host doesn't have a port.
+func canonicalSelfHost(host string) string {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname, port = strings.Trim(host, "[]"), ""
+	}
+	hostname = canonicalHostname(hostname)
+	if port == "" || port == "80" || port == "443" {
+		if strings.Contains(hostname, ":") {
+			return "[" + hostname + "]"
+		}
+		return hostname
+	}
+	return net.JoinHostPort(hostname, port)
+}
@@ -0,0 +1,94 @@
+package validator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marcuscaisey/gophercises/urlshort/v2/errors"
+	"github.com/marcuscaisey/gophercises/urlshort/v2/errors/codes"
+)
+
+func TestValidate(t *testing.T) {
+	blocklistPath := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(blocklistPath, []byte("# Phishing\nevil.com\n\n*.bad.org\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	v, err := New([]string{"sho.rt", "localhost:8080"}, blocklistPath)
+	if err != nil {
+		t.Fatalf("New returned unexpected err: %s", err)
+	}
+
+	testCases := []struct {
+		longURL string
+		want    string
+		wantErr string
+	}{
+		{longURL: "HTTP://Example.COM/Path", want: "http://example.com/Path"},
+		{longURL: "https://example.com:443/", want: "https://example.com/"},
+		{longURL: "http://example.com:443/", want: "http://example.com:443/"},
+		{longURL: "https://example.com./?q=1#top", want: "https://example.com/?q=1#top"},
+		{longURL: "http://[::1]:80/", want: "http://[::1]/"},
+		{longURL: "http://[::1]:8081/", want: "http://[::1]:8081/"},
+		{longURL: "javascript:alert(1)", wantErr: "long_url must be an absolute http or https URL."},
+		{longURL: "/relative/path", wantErr: "long_url must be an absolute http or https URL."},
+		{longURL: "ftp://example.com/", wantErr: "long_url must be an absolute http or https URL."},
+		{longURL: "http://%zz", wantErr: "long_url is not a valid URL."},
+		{longURL: "https://SHO.RT:443/abc", wantErr: "long_url must not point to this URL shortener."},
+		{longURL: "http://localhost:8080/abc", wantErr: "long_url must not point to this URL shortener."},
+		{longURL: "http://api.shortener.test/abc", wantErr: "long_url must not point to this URL shortener."},
+		{longURL: "http://localhost:3000/abc", want: "http://localhost:3000/abc"},
+		{longURL: "https://EVIL.com/", wantErr: "long_url host evil.com is blocked because evil.com is on the blocklist."},
+		{longURL: "https://a.b.bad.org/", wantErr: "long_url host a.b.bad.org is blocked because bad.org is on the blocklist."},
+		{longURL: "https://notevil.com/", want: "https://notevil.com/"},
+	}
+
+	for _, tc := range testCases {
+		got, err := v.Validate(tc.longURL, "api.shortener.test")
+		if tc.wantErr != "" {
+			if errors.Code(err) != codes.BadRequest || errors.Message(err) != tc.wantErr {
+				t.Errorf("Validate(%q) returned err %v, want BadRequest error %q", tc.longURL, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Validate(%q) returned unexpected err: %s", tc.longURL, err)
+		} else if got != tc.want {
+			t.Errorf("Validate(%q) = %q, want %q", tc.longURL, got, tc.want)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	blocklistPath := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(blocklistPath, []byte("evil.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	v, err := New(nil, blocklistPath)
+	if err != nil {
+		t.Fatalf("New returned unexpected err: %s", err)
+	}
+
+	if err := os.WriteFile(blocklistPath, []byte("worse.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Reload(); err != nil {
+		t.Fatalf("Reload returned unexpected err: %s", err)
+	}
+	if _, err := v.Validate("https://evil.com", ""); err != nil {
+		t.Errorf("Validate of domain removed from blocklist returned unexpected err: %s", err)
+	}
+	if _, err := v.Validate("https://worse.com", ""); err == nil {
+		t.Errorf("Validate of domain added to blocklist returned nil err, want an error")
+	}
+
+	if err := os.WriteFile(blocklistPath, []byte("not a domain\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Reload(); err == nil {
+		t.Errorf("Reload of invalid blocklist returned nil err, want an error")
+	}
+	if _, err := v.Validate("https://worse.com", ""); err == nil {
+		t.Errorf("Validate after failed Reload returned nil err, want the old blocklist to be kept")
+	}
+}